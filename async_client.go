@@ -16,7 +16,10 @@ type AsyncResult[T any] struct {
 //
 // Example usage:
 //
-//	asyncClient := xiangxinai.NewAsyncClient("your-api-key")
+//	asyncClient, err := xiangxinai.NewAsyncClient("your-api-key")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
 //	defer asyncClient.Close()
 //	
 //	// Async check prompt
@@ -50,29 +53,38 @@ type AsyncClient struct {
 	closeMu    sync.RWMutex
 }
 
-// NewAsyncClient Create new async client, using default configuration
-func NewAsyncClient(apiKey string) *AsyncClient {
-	return NewAsyncClientWithConfig(&ClientConfig{
-		APIKey:     apiKey,
-		BaseURL:    DefaultBaseURL,
-		Timeout:    DefaultTimeout,
-		MaxRetries: DefaultMaxRetries,
-	}, 10) // Default concurrency is 10
+// NewAsyncClient Create new async client, using default concurrency (10)
+//
+// Options are the same as for NewClient.
+func NewAsyncClient(apiKey string, opts ...Option) (*AsyncClient, error) {
+	client, err := NewClient(apiKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncClientWithClient(client, 10), nil // Default concurrency is 10
 }
 
-// NewAsyncClientWithConfig Create new async client, using custom configuration
-func NewAsyncClientWithConfig(config *ClientConfig, maxConcurrency int) *AsyncClient {
+// NewAsyncClientWithClient Create new async client wrapping an existing client
+func NewAsyncClientWithClient(client *Client, maxConcurrency int) *AsyncClient {
 	if maxConcurrency <= 0 {
 		maxConcurrency = 10
 	}
 	
 	return &AsyncClient{
-		client:     NewClientWithConfig(config),
+		client:     client,
 		workerPool: make(chan struct{}, maxConcurrency),
 		closed:     false,
 	}
 }
 
+// NewAsyncClientWithConfig Create new async client, using custom configuration
+//
+// Deprecated: Use NewAsyncClient or NewAsyncClientWithClient instead.
+// NewAsyncClientWithConfig panics if the configuration is invalid.
+func NewAsyncClientWithConfig(config *ClientConfig, maxConcurrency int) *AsyncClient {
+	return NewAsyncClientWithClient(NewClientWithConfig(config), maxConcurrency)
+}
+
 // CheckPromptAsync Async check prompt safety
 //
 // Parameters:
@@ -239,44 +251,9 @@ func (ac *AsyncClient) BatchCheckPromptsWithModel(ctx context.Context, contents
 	}
 	ac.closeMu.RUnlock()
 	
-	go func() {
-		defer close(resultChan)
-		
-		// Create result collector, keep order
-		results := make([]AsyncResult[*GuardrailResponse], len(contents))
-		var wg sync.WaitGroup
-		
-		for i, content := range contents {
-			wg.Add(1)
-			go func(index int, content string) {
-				defer wg.Done()
-				
-				// Get worker slot
-				select {
-				case ac.workerPool <- struct{}{}:
-					defer func() { <-ac.workerPool }()
-				case <-ctx.Done():
-					results[index] = AsyncResult[*GuardrailResponse]{Error: ctx.Err()}
-					return
-				}
-				
-				// Execute detection
-				result, err := ac.client.CheckPromptWithModel(ctx, content, model)
-				results[index] = AsyncResult[*GuardrailResponse]{Result: result, Error: err}
-			}(i, content)
-		}
-		
-		wg.Wait()
-		
-		// Send results in order
-		for _, result := range results {
-			select {
-			case resultChan <- result:
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+	go ac.batch(ctx, resultChan, len(contents), func(index int) (*GuardrailResponse, error) {
+		return ac.client.CheckPromptWithModel(ctx, contents[index], model)
+	})
 	
 	return resultChan
 }
@@ -325,46 +302,53 @@ func (ac *AsyncClient) BatchCheckConversationsWithModel(ctx context.Context, con
 	}
 	ac.closeMu.RUnlock()
 	
-	go func() {
-		defer close(resultChan)
-		
-		// Create result collector, keep order
-		results := make([]AsyncResult[*GuardrailResponse], len(conversations))
-		var wg sync.WaitGroup
-		
-		for i, messages := range conversations {
-			wg.Add(1)
-			go func(index int, messages []*Message) {
-				defer wg.Done()
-				
-				// Get worker slot
-				select {
-				case ac.workerPool <- struct{}{}:
-					defer func() { <-ac.workerPool }()
-				case <-ctx.Done():
-					results[index] = AsyncResult[*GuardrailResponse]{Error: ctx.Err()}
-					return
-				}
-				
-				// Execute detection
-				result, err := ac.client.CheckConversationWithModel(ctx, messages, model)
-				results[index] = AsyncResult[*GuardrailResponse]{Result: result, Error: err}
-			}(i, messages)
+	go ac.batch(ctx, resultChan, len(conversations), func(index int) (*GuardrailResponse, error) {
+		return ac.client.CheckConversationWithModel(ctx, conversations[index], model)
+	})
+	
+	return resultChan
+}
+
+// batch Run check for items 0 to n-1 and send the results to resultChan in order
+//
+// Items run on a pool of BatchConfig.Concurrency workers, each also holding a
+// slot of the worker pool. Items not started before ctx is done fail with the
+// context error.
+func (ac *AsyncClient) batch(ctx context.Context, resultChan chan<- AsyncResult[*GuardrailResponse], n int, check func(index int) (*GuardrailResponse, error)) {
+	defer close(resultChan)
+	
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	
+	// Create result collector, keep order
+	results := make([]AsyncResult[*GuardrailResponse], n)
+	forEach(ctx, ac.client.batchConfig.Concurrency, indexes, func(index int) {
+		// Get worker slot
+		select {
+		case ac.workerPool <- struct{}{}:
+			defer func() { <-ac.workerPool }()
+		case <-ctx.Done():
+			results[index] = AsyncResult[*GuardrailResponse]{Error: ctx.Err()}
+			return
 		}
 		
-		wg.Wait()
-		
-		// Send results in order
-		for _, result := range results {
-			select {
-			case resultChan <- result:
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+		// Execute detection
+		result, err := check(index)
+		results[index] = AsyncResult[*GuardrailResponse]{Result: result, Error: err}
+	}, func(index int) {
+		results[index] = AsyncResult[*GuardrailResponse]{Error: ctx.Err()}
+	})
 	
-	return resultChan
+	// Send results in order
+	for _, result := range results {
+		select {
+		case resultChan <- result:
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 // HealthCheckAsync Async check API service health status
@@ -0,0 +1,96 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// inFlightTransport Record the highest number of concurrent requests
+type inFlightTransport struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (t *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.inFlight++
+	if t.inFlight > t.max {
+		t.max = t.inFlight
+	}
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		t.inFlight--
+		t.mu.Unlock()
+	}()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestAsyncBatchUsesBatchConcurrency(t *testing.T) {
+	transport := &inFlightTransport{}
+	client, server := xiangxintest.NewClient(t,
+		xiangxinai.WithTransport(transport),
+		xiangxinai.WithBatch(xiangxinai.BatchConfig{Concurrency: 2}),
+	)
+	server.SetLatency(20 * time.Millisecond)
+	server.OnContent("bad", xiangxintest.Verdict{Categories: []string{"Violent Crime"}})
+	async := xiangxinai.NewAsyncClientWithClient(client, 10)
+	defer async.Close()
+
+	contents := make([]string, 8)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("content %d", i)
+	}
+	contents[5] = "bad"
+
+	var results []xiangxinai.AsyncResult[*xiangxinai.GuardrailResponse]
+	for result := range async.BatchCheckPrompts(context.Background(), contents) {
+		results = append(results, result)
+	}
+	require.Len(t, results, len(contents))
+	for i, result := range results {
+		require.NoError(t, result.Error)
+		assert.Equal(t, i == 5, result.Result.IsBlocked(), "result %d", i)
+	}
+	assert.Equal(t, 2, transport.max)
+
+	conversations := make([][]*xiangxinai.Message, 6)
+	for i := range conversations {
+		conversations[i] = []*xiangxinai.Message{xiangxinai.NewMessage("user", contents[i])}
+	}
+	transport.max = 0
+	count := 0
+	for result := range async.BatchCheckConversations(context.Background(), conversations) {
+		require.NoError(t, result.Error)
+		count++
+	}
+	assert.Equal(t, len(conversations), count)
+	assert.Equal(t, 2, transport.max)
+}
+
+func TestAsyncBatchSkipsItemsAfterCancellation(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithBatch(xiangxinai.BatchConfig{Concurrency: 1}))
+	server.SetLatency(50 * time.Millisecond)
+	async := xiangxinai.NewAsyncClientWithClient(client, 10)
+	defer async.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	results := async.BatchCheckPrompts(ctx, []string{"a", "b", "c"})
+	for range results {
+	}
+	// Results are not sent after the context is done, but the channel is closed
+	// and no more than the first item was sent to the server
+	assert.LessOrEqual(t, server.RequestCount(), 1)
+}
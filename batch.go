@@ -0,0 +1,387 @@
+package xiangxinai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultBatchChunkSize Default number of items per batch request
+	DefaultBatchChunkSize = 100
+	// DefaultBatchConcurrency Default number of batch (or fallback single) requests in flight
+	DefaultBatchConcurrency = 10
+
+	// batchEndpoint Batch detection endpoint
+	batchEndpoint = "/guardrails/batch"
+)
+
+// BatchConfig Batch detection configuration, used with WithBatch
+type BatchConfig struct {
+	ChunkSize       int  // Items per batch request, default 100
+	Concurrency     int  // Requests in flight, default 10
+	DisableEndpoint bool // Always use single requests instead of the batch endpoint
+}
+
+// BatchItem Batch detection item
+type BatchItem struct {
+	ID       string     // Caller-supplied ID, unique within the batch
+	Messages []*Message // Messages to check, a single user message for prompts
+	Model    string     // Model name, default DefaultModel
+	UserID   string     // Optional tenant AI application user ID
+}
+
+// NewPromptBatchItem Create batch item checking a user prompt
+func NewPromptBatchItem(id, content string, userID ...string) BatchItem {
+	item := BatchItem{ID: id, Messages: []*Message{NewMessage("user", content)}}
+	if len(userID) > 0 {
+		item.UserID = userID[0]
+	}
+	return item
+}
+
+// BatchResult Result of a batch item
+type BatchResult struct {
+	ID     string             // Item ID
+	Result *GuardrailResponse // Detection result, nil if Err is set
+	Err    error              // Item error
+}
+
+// BatchResponse Item results of a /guardrails/batch request, see BatchInterceptor
+type BatchResponse struct {
+	Results map[string]BatchResult // Item results keyed by item ID
+}
+
+// batchResults Return the item results of a batch response, nil for other responses
+func (r *GuardrailResponse) batchResults() map[string]BatchResult {
+	if r == nil || r.batch == nil {
+		return nil
+	}
+	return r.batch.Results
+}
+
+// ErrBatchUnsupported Returned through the interceptor chain when the server has no batch endpoint
+//
+// CheckBatch then checks the items one request at a time.
+var ErrBatchUnsupported = errors.New("batch endpoint is not supported")
+
+// BatchRequest Body of a /guardrails/batch request, a chunk of CheckBatch items
+type BatchRequest struct {
+	Items []BatchRequestItem `json:"items"`
+}
+
+// BatchRequestItem Item of a batch request
+type BatchRequestItem struct {
+	ID string `json:"id"`
+	*GuardrailRequest
+}
+
+// batchResponse Batch endpoint response
+type batchResponse struct {
+	Results []json.RawMessage `json:"results"`
+}
+
+// batchResponseItem Batch response item, with either a result or an error
+type batchResponseItem struct {
+	ID     string          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// batchItemError Error of a batch response item
+type batchItemError struct {
+	StatusCode int `json:"status_code"`
+}
+
+// CheckBatch Check many items, chunked into batch requests
+//
+// The batch endpoint accepts {"items": [{"id", "model", "messages", "extra_body"}]}
+// and returns {"results": [{"id", "result"}]}, where failed items carry
+// {"id", "error": {"status_code", "detail"}} instead of a result.
+//
+// Items are sent to the batch endpoint in chunks of BatchConfig.ChunkSize. If
+// the server does not support batching (404, 405 or 501), the client falls
+// back to one request per item with at most BatchConfig.Concurrency requests
+// in flight, and remembers this for later calls. Each chunk passes through
+// the interceptor chain as one request with a *BatchRequest body, see
+// BatchInterceptor.
+//
+// Requests run on a pool of BatchConfig.Concurrency workers. Items not sent
+// before ctx is done get a BatchResult.Err wrapping ctx.Err().
+//
+// Results are keyed by item ID; per-item failures are reported in
+// BatchResult.Err. The returned error is only set for invalid input, e.g.
+// empty or duplicate IDs.
+//
+// Example:
+//
+//	results, err := client.CheckBatch(ctx, []xiangxinai.BatchItem{
+//		xiangxinai.NewPromptBatchItem("q1", "How do I reset my password?"),
+//		xiangxinai.NewPromptBatchItem("q2", "Tell me a joke"),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for id, r := range results {
+//		if r.Err != nil {
+//			log.Printf("%s failed: %v", id, r.Err)
+//			continue
+//		}
+//		fmt.Println(id, r.Result.SuggestAction)
+//	}
+func (c *Client) CheckBatch(ctx context.Context, items []BatchItem) (map[string]BatchResult, error) {
+	results := make(map[string]BatchResult, len(items))
+	var mu sync.Mutex
+	setResult := func(id string, result *GuardrailResponse, err error) {
+		mu.Lock()
+		results[id] = BatchResult{ID: id, Result: result, Err: err}
+		mu.Unlock()
+	}
+
+	// Validate items; empty items are answered locally
+	pending := make([]BatchRequestItem, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for i, item := range items {
+		if item.ID == "" {
+			return nil, NewValidationError(fmt.Sprintf("batch item %d: ID cannot be empty", i))
+		}
+		if seen[item.ID] {
+			return nil, NewValidationError(fmt.Sprintf("duplicate batch item ID: %s", item.ID))
+		}
+		seen[item.ID] = true
+
+		request, err := newBatchRequest(item)
+		if err != nil {
+			setResult(item.ID, nil, err)
+			continue
+		}
+		if request == nil {
+			setResult(item.ID, c.createSafeResponse(), nil)
+			continue
+		}
+		pending = append(pending, BatchRequestItem{ID: item.ID, GuardrailRequest: request})
+	}
+
+	config := c.batchConfig
+	canceled := func(items []BatchRequestItem) {
+		err := NewNetworkError("request failed", ctx.Err())
+		for _, item := range items {
+			setResult(item.ID, nil, err)
+		}
+	}
+
+	// fallback Check items one request at a time
+	fallback := func(items []BatchRequestItem) {
+		forEach(ctx, config.Concurrency, items, func(item BatchRequestItem) {
+			result, err := c.makeRequest(ctx, "POST", "/guardrails", item.GuardrailRequest)
+			setResult(item.ID, result, err)
+		}, func(item BatchRequestItem) {
+			canceled([]BatchRequestItem{item})
+		})
+	}
+
+	if config.DisableEndpoint || atomic.LoadInt32(&c.batchUnsupported) == 1 {
+		fallback(pending)
+		return results, nil
+	}
+
+	var chunks [][]BatchRequestItem
+	for start := 0; start < len(pending); start += config.ChunkSize {
+		end := start + config.ChunkSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		chunks = append(chunks, pending[start:end])
+	}
+	var unsupported []BatchRequestItem
+	forEach(ctx, config.Concurrency, chunks, func(chunk []BatchRequestItem) {
+		var resp *GuardrailResponse
+		err := ErrBatchUnsupported
+		if atomic.LoadInt32(&c.batchUnsupported) == 0 {
+			resp, err = c.makeRequestWithData(ctx, "POST", batchEndpoint, &BatchRequest{Items: chunk})
+		}
+		switch {
+		case errors.Is(err, ErrBatchUnsupported):
+			mu.Lock()
+			unsupported = append(unsupported, chunk...)
+			mu.Unlock()
+			return
+		case err != nil:
+			for _, item := range chunk {
+				setResult(item.ID, nil, err)
+			}
+			return
+		}
+		for _, item := range chunk {
+			if result, ok := resp.batchResults()[item.ID]; ok {
+				setResult(item.ID, result.Result, result.Err)
+			} else {
+				setResult(item.ID, nil, NewXiangxinAIError(fmt.Sprintf("batch response is missing item %s", item.ID), nil))
+			}
+		}
+	}, canceled)
+
+	// Chunks the server could not batch, once all chunks are done
+	fallback(unsupported)
+	return results, nil
+}
+
+// forEach Call fn for each job on a pool of at most workers goroutines
+//
+// Jobs not started before ctx is done are passed to skip instead.
+func forEach[T any](ctx context.Context, workers int, jobs []T, fn func(T), skip func(T)) {
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	queue := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				fn(job)
+			}
+		}()
+	}
+
+	for i, job := range jobs {
+		if ctx.Err() == nil {
+			select {
+			case queue <- job:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		for _, job := range jobs[i:] {
+			skip(job)
+		}
+		break
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// batchResponse Build the response of a /guardrails/batch request from the response body or the error of sending it
+//
+// The item results are returned in a BatchResponse, see BatchInterceptor. A
+// server without batch support yields ErrBatchUnsupported; other errors
+// degrade every item as configured by the failure mode.
+func (c *Client) batchResponse(ctx context.Context, req *Request, body []byte, err error) (*GuardrailResponse, error) {
+	batch, ok := req.Body.(*BatchRequest)
+	if !ok {
+		return nil, NewXiangxinAIError(fmt.Sprintf("batch request body must be *BatchRequest, got %T", req.Body), nil)
+	}
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && isBatchUnsupported(apiErr.StatusCode) {
+			atomic.StoreInt32(&c.batchUnsupported, 1)
+			return nil, ErrBatchUnsupported
+		}
+		if _, degradeErr := c.degrade(ctx, batchEndpoint, err); degradeErr != nil {
+			return nil, degradeErr
+		}
+		// Every item gets its own response, which the policy and redaction modify
+		results := make(map[string]BatchResult, len(batch.Items))
+		for _, item := range batch.Items {
+			results[item.ID] = BatchResult{ID: item.ID, Result: c.createDegradedResponse(err)}
+		}
+		return &GuardrailResponse{batch: &BatchResponse{Results: results}}, nil
+	}
+
+	var data batchResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		if c.log != nil {
+			c.log.parseFailed(ctx, batchEndpoint, body, err)
+		}
+		return nil, NewXiangxinAIError("failed to parse response", err)
+	}
+
+	results := make(map[string]BatchResult, len(batch.Items))
+	requested := make(map[string]bool, len(batch.Items))
+	for _, item := range batch.Items {
+		requested[item.ID] = true
+	}
+	parseFailed := func(raw []byte, err error) {
+		if c.log != nil {
+			c.log.parseFailed(ctx, batchEndpoint, raw, err)
+		}
+	}
+	var itemParseErr error // First result item that could not be parsed, so its ID is unknown
+	for _, raw := range data.Results {
+		var item batchResponseItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			parseFailed(raw, err)
+			if itemParseErr == nil {
+				itemParseErr = err
+			}
+			continue
+		}
+		if _, done := results[item.ID]; !requested[item.ID] || done {
+			continue
+		}
+
+		if len(item.Error) > 0 && string(item.Error) != "null" {
+			var itemErr batchItemError
+			err := json.Unmarshal(item.Error, &itemErr)
+			if err == nil && itemErr.StatusCode == 0 {
+				err = errors.New("missing status_code")
+			}
+			if err != nil {
+				parseFailed(raw, err)
+				results[item.ID] = BatchResult{ID: item.ID, Err: NewXiangxinAIError("failed to parse batch item error", err)}
+				continue
+			}
+			results[item.ID] = BatchResult{ID: item.ID, Err: wrapAPIError(newAPIError(itemErr.StatusCode, http.Header{}, item.Error))}
+			continue
+		}
+
+		var result GuardrailResponse
+		if err := json.Unmarshal(item.Result, &result); err != nil {
+			parseFailed(raw, err)
+			results[item.ID] = BatchResult{ID: item.ID, Err: NewXiangxinAIError("failed to parse response", err)}
+			continue
+		}
+		results[item.ID] = BatchResult{ID: item.ID, Result: &result}
+	}
+
+	// Requested items without a result may be the ones that could not be parsed
+	if itemParseErr != nil {
+		for id := range requested {
+			if _, ok := results[id]; !ok {
+				results[id] = BatchResult{ID: id, Err: NewXiangxinAIError("failed to parse batch response item", itemParseErr)}
+			}
+		}
+	}
+	return &GuardrailResponse{batch: &BatchResponse{Results: results}}, nil
+}
+
+// newBatchRequest Build the request of a batch item, nil if all messages are empty
+func newBatchRequest(item BatchItem) (*GuardrailRequest, error) {
+	if len(item.Messages) == 0 {
+		return nil, NewValidationError("messages cannot be empty")
+	}
+	messages, err := validateMessages(item.Messages)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+
+	model := item.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	request := &GuardrailRequest{Model: model, Messages: messages}
+	if item.UserID != "" {
+		request.ExtraBody = map[string]interface{}{"xxai_app_user_id": item.UserID}
+	}
+	return request, nil
+}
+
+// isBatchUnsupported Check if the status code means the batch endpoint is not available
+func isBatchUnsupported(statusCode int) bool {
+	return statusCode == http.StatusNotFound || statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented
+}
@@ -0,0 +1,282 @@
+package xiangxinai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerFailureThreshold Default consecutive failure count that opens the circuit
+	DefaultBreakerFailureThreshold = 5
+	// DefaultBreakerOpenTimeout Default time the circuit stays open before a half-open probe
+	DefaultBreakerOpenTimeout = 30 * time.Second
+	// DefaultBreakerProbeTimeout Default timeout of the half-open health check probe
+	DefaultBreakerProbeTimeout = 5 * time.Second
+
+	// DefaultFailClosedAnswer Default suggested answer of FailClosed degraded responses
+	DefaultFailClosedAnswer = "Sorry, the content safety service is temporarily unavailable. Please try again later."
+)
+
+// ErrCircuitOpen Returned (wrapped in a NetworkError) when the circuit breaker rejects a request
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState Circuit breaker state
+type CircuitState int
+
+const (
+	// CircuitClosed Requests are sent normally
+	CircuitClosed CircuitState = iota
+	// CircuitOpen Requests are rejected without calling the API
+	CircuitOpen
+	// CircuitHalfOpen A health check probe decides whether to close the circuit
+	CircuitHalfOpen
+)
+
+// String Return the state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig Circuit breaker configuration, used with WithCircuitBreaker
+//
+// The circuit opens after FailureThreshold consecutive network or server errors.
+// While open, Check* calls fail immediately with ErrCircuitOpen. After OpenTimeout,
+// the next call probes the /guardrails/health endpoint: on success the circuit
+// closes and the call proceeds, otherwise it stays open for another OpenTimeout.
+type CircuitBreakerConfig struct {
+	FailureThreshold int                         // Consecutive failures that open the circuit, default 5
+	OpenTimeout      time.Duration               // Time before a half-open probe, default 30s
+	ProbeTimeout     time.Duration               // Health check probe timeout, default 5s
+	OnStateChange    func(from, to CircuitState) // Optional state change callback
+}
+
+// FailureMode Behavior of Check* calls when the guardrail service is unavailable
+type FailureMode int
+
+const (
+	// FailureModeError Return the error to the caller (default)
+	FailureModeError FailureMode = iota
+	// FailOpen Return a degraded pass result, favoring availability
+	FailOpen
+	// FailClosed Return a degraded reject result with a canned answer, favoring safety
+	FailClosed
+)
+
+// String Return the failure mode name
+func (m FailureMode) String() string {
+	switch m {
+	case FailureModeError:
+		return "error"
+	case FailOpen:
+		return "fail_open"
+	case FailClosed:
+		return "fail_closed"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker Client-side circuit breaker
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	probe  func(ctx context.Context) error
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker Create circuit breaker, filling in defaults
+func newCircuitBreaker(config CircuitBreakerConfig, probe func(ctx context.Context) error) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultBreakerFailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	if config.ProbeTimeout <= 0 {
+		config.ProbeTimeout = DefaultBreakerProbeTimeout
+	}
+	return &circuitBreaker{
+		config: config,
+		probe:  probe,
+		state:  CircuitClosed,
+	}
+}
+
+// State Return the current state
+func (b *circuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow Check whether a request may be sent, probing the service if the open timeout elapsed
+func (b *circuitBreaker) allow(ctx context.Context) error {
+	b.mu.Lock()
+	if b.state == CircuitClosed {
+		b.mu.Unlock()
+		return nil
+	}
+	if b.state == CircuitHalfOpen || time.Since(b.openedAt) < b.config.OpenTimeout {
+		// Still open, or another caller is probing
+		b.mu.Unlock()
+		return NewNetworkError("request rejected", ErrCircuitOpen)
+	}
+	from := b.setState(CircuitHalfOpen)
+	b.mu.Unlock()
+	b.notify(from, CircuitHalfOpen)
+
+	probeCtx, cancel := context.WithTimeout(ctx, b.config.ProbeTimeout)
+	err := b.probe(probeCtx)
+	cancel()
+
+	b.mu.Lock()
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up, which says nothing about the service: reopen
+		// without restarting the open timeout so the next call probes again
+		from = b.setState(CircuitOpen)
+		b.mu.Unlock()
+		b.notify(from, CircuitOpen)
+		return NewNetworkError("request failed", ctx.Err())
+	}
+	if err != nil {
+		b.openedAt = time.Now()
+		from = b.setState(CircuitOpen)
+		b.mu.Unlock()
+		b.notify(from, CircuitOpen)
+		return NewNetworkError("request rejected", ErrCircuitOpen)
+	}
+	b.failures = 0
+	from = b.setState(CircuitClosed)
+	b.mu.Unlock()
+	b.notify(from, CircuitClosed)
+	return nil
+}
+
+// record Record the result of a request
+//
+// Requests ended by the caller's cancellation or deadline are not counted.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	b.mu.Lock()
+	if !isUnavailableError(ctx, err) {
+		b.failures = 0
+		b.mu.Unlock()
+		return
+	}
+
+	b.failures++
+	if b.state != CircuitClosed || b.failures < b.config.FailureThreshold {
+		b.mu.Unlock()
+		return
+	}
+	b.openedAt = time.Now()
+	from := b.setState(CircuitOpen)
+	b.mu.Unlock()
+	b.notify(from, CircuitOpen)
+}
+
+// setState Set state and return the previous one, must be called with mu held
+func (b *circuitBreaker) setState(state CircuitState) CircuitState {
+	from := b.state
+	b.state = state
+	return from
+}
+
+// notify Call the state change callback, must be called without mu held
+func (b *circuitBreaker) notify(from, to CircuitState) {
+	if from != to && b.config.OnStateChange != nil {
+		b.config.OnStateChange(from, to)
+	}
+}
+
+// isUnavailableError Check if the error means the service is unavailable
+//
+// Network errors, server errors and open circuit errors count, unless the
+// caller's own context was cancelled.
+func isUnavailableError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var networkErr *NetworkError
+	var serverErr *ServerError
+	return errors.As(err, &networkErr) || errors.As(err, &serverErr)
+}
+
+// healthProbe Send a single health check request, without retries
+func (c *Client) healthProbe(ctx context.Context) error {
+	resp, err := c.client.R().SetContext(ctx).Execute(http.MethodGet, "/guardrails/health")
+	if err != nil {
+		return NewNetworkError("health check failed", err)
+	}
+	if resp.IsError() {
+		return c.handleErrorResponse(resp)
+	}
+	return nil
+}
+
+// CircuitState Return the circuit breaker state, CircuitClosed if no breaker is configured
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	return c.breaker.State()
+}
+
+// degrade Apply the failure mode to an error returned by a Check* request
+//
+// Unavailable errors become a degraded response with FailOpen and FailClosed;
+// the response is logged and its cause recorded for telemetry. Other errors,
+// and all errors with FailureModeError, are returned unchanged.
+func (c *Client) degrade(ctx context.Context, endpoint string, err error) (*GuardrailResponse, error) {
+	if c.failureMode == FailureModeError || !isUnavailableError(ctx, err) {
+		return nil, err
+	}
+	setDegradedCause(ctx, err)
+	if c.log != nil {
+		c.log.degraded(ctx, endpoint, c.failureMode, err)
+	}
+	return c.createDegradedResponse(err), nil
+}
+
+// createDegradedResponse Create synthesized response according to the failure mode
+//
+// FailOpen responses pass and FailClosed responses reject with the configured
+// answer; both have no risk in every dimension and are marked Degraded.
+func (c *Client) createDegradedResponse(cause error) *GuardrailResponse {
+	resp := &GuardrailResponse{
+		ID: "guardrails-degraded-" + strings.ReplaceAll(c.failureMode.String(), "_", "-"),
+		Result: &GuardrailResult{
+			Compliance: &ComplianceResult{RiskLevel: NoRisk, Categories: []string{}},
+			Security:   &SecurityResult{RiskLevel: NoRisk, Categories: []string{}},
+			Data:       &DataSecurityResult{RiskLevel: NoRisk, Categories: []string{}},
+		},
+		OverallRiskLevel: NoRisk,
+		SuggestAction:    ActionPass,
+		Degraded:         true,
+		DegradedReason:   cause.Error(),
+	}
+	if c.failureMode == FailClosed {
+		answer := c.failClosedAnswer
+		resp.SuggestAction = ActionReject
+		resp.SuggestAnswer = &answer
+	}
+	return resp
+}
@@ -0,0 +1,95 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	var mu sync.Mutex
+	var transitions []string
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithCircuitBreaker(xiangxinai.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      100 * time.Millisecond,
+		OnStateChange: func(from, to xiangxinai.CircuitState) {
+			mu.Lock()
+			transitions = append(transitions, from.String()+"->"+to.String())
+			mu.Unlock()
+		},
+	}))
+	ctx := context.Background()
+
+	server.Fail(xiangxintest.Fault{Status: http.StatusServiceUnavailable, Times: 2})
+	for i := 0; i < 2; i++ {
+		_, err := client.CheckPrompt(ctx, "hello")
+		require.Error(t, err)
+	}
+	assert.Equal(t, xiangxinai.CircuitOpen, client.CircuitState())
+
+	// Rejected without calling the API
+	_, err := client.CheckPrompt(ctx, "hello")
+	assert.True(t, errors.Is(err, xiangxinai.ErrCircuitOpen), "got %v", err)
+	assert.Equal(t, 2, server.RequestCount())
+
+	// A successful health probe closes the circuit
+	time.Sleep(150 * time.Millisecond)
+	_, err = client.CheckPrompt(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, xiangxinai.CircuitClosed, client.CircuitState())
+	assert.Equal(t, 1, server.RequestCount("/guardrails/health"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"closed->open", "open->half_open", "half_open->closed"}, transitions)
+}
+
+func TestCircuitBreakerIgnoresCallerCancellation(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithCircuitBreaker(xiangxinai.CircuitBreakerConfig{FailureThreshold: 1}))
+	server.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.CheckPrompt(ctx, "hello")
+	require.Error(t, err)
+	assert.Equal(t, xiangxinai.CircuitClosed, client.CircuitState())
+}
+
+func TestFailureModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode   xiangxinai.FailureMode
+		action xiangxinai.Action
+	}{
+		{xiangxinai.FailOpen, xiangxinai.ActionPass},
+		{xiangxinai.FailClosed, xiangxinai.ActionReject},
+	} {
+		t.Run(tc.mode.String(), func(t *testing.T) {
+			client, server := xiangxintest.NewClient(t, xiangxinai.WithFailureMode(tc.mode))
+			server.Fail(xiangxintest.Fault{Status: http.StatusServiceUnavailable})
+
+			result, err := client.CheckPrompt(context.Background(), "hello")
+			require.NoError(t, err)
+			assert.True(t, result.Degraded)
+			assert.NotEmpty(t, result.DegradedReason)
+			assert.Equal(t, tc.action, result.SuggestAction)
+			if tc.mode == xiangxinai.FailClosed {
+				assert.Equal(t, xiangxinai.DefaultFailClosedAnswer, *result.SuggestAnswer)
+			}
+
+			// Errors that are not outages are still returned
+			server.ClearFaults()
+			server.FailNext(http.StatusUnauthorized)
+			_, err = client.CheckPrompt(context.Background(), "hello")
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package xiangxinai
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL Default time-to-live of cached responses
+	DefaultCacheTTL = 10 * time.Minute
+	// DefaultCacheMaxEntries Default maximum entry count of the LRU cache
+	DefaultCacheMaxEntries = 10000
+	// DefaultCacheMaxBytes Default maximum size of the LRU cache
+	DefaultCacheMaxBytes = 64 << 20
+)
+
+// Cache Response cache, used with WithCache
+//
+// Keys are hashes of the client and the request, values are JSON encoded
+// GuardrailResponse values.
+// Implementations must be safe for concurrent use; errors of remote caches
+// (e.g. Redis) should be treated as misses.
+type Cache interface {
+	// Get Return the cached value of key
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set Store value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// CacheConfig Response cache configuration, used with WithCache
+//
+// Responses of Check* calls are cached under a SHA-256 hash of the API key, the
+// base URL, the endpoint and the normalized request body, which contains the
+// model, messages and user ID, so a Cache can be shared by clients of different
+// accounts or servers. Values are the JSON encoded GuardrailResponse. Cached
+// responses have Cached set to true. Health checks and degraded responses are
+// never cached.
+type CacheConfig struct {
+	Cache       Cache                              // Cache backend, default NewLRUCache(DefaultCacheMaxEntries, DefaultCacheMaxBytes)
+	TTL         time.Duration                      // Time-to-live, default 10 minutes
+	ShouldCache func(resp *GuardrailResponse) bool // Cache decision, default only no_risk responses
+}
+
+// responseCache Client response cache
+type responseCache struct {
+	cache       Cache
+	ttl         time.Duration
+	shouldCache func(resp *GuardrailResponse) bool
+	scope       string // Cache scope of the client, see cacheScope
+}
+
+// newResponseCache Create response cache, applying defaults
+func newResponseCache(config CacheConfig, scope string) *responseCache {
+	if config.Cache == nil {
+		config.Cache = NewLRUCache(DefaultCacheMaxEntries, DefaultCacheMaxBytes)
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultCacheTTL
+	}
+	if config.ShouldCache == nil {
+		config.ShouldCache = isNoRisk
+	}
+	return &responseCache{
+		cache:       config.Cache,
+		ttl:         config.TTL,
+		shouldCache: config.ShouldCache,
+		scope:       scope,
+	}
+}
+
+// cacheScope Return the hash of the API key and base URL that scopes cache keys
+func cacheScope(apiKey, baseURL string) string {
+	sum := sha256.Sum256([]byte(apiKey + "\x00" + baseURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// isNoRisk Check if the response reports no risk in any dimension
+func isNoRisk(resp *GuardrailResponse) bool {
+	return resp.SuggestAction == ActionPass && resp.MaxRiskLevel().Compare(NoRisk) <= 0
+}
+
+// LRUCache In-memory LRU cache with per-entry TTL, limited by entry count and size
+type LRUCache struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+// lruEntry LRU cache entry
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache Create LRU cache, zero or negative limits are unlimited
+func NewLRUCache(maxEntries int, maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get Return the cached value of key
+func (c *LRUCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.removeLocked(elem)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set Store value under key for ttl, evicting least recently used entries
+func (c *LRUCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	size := int64(len(key) + len(value))
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeLocked(elem)
+	}
+	entry := &lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	c.items[key] = c.ll.PushFront(entry)
+	c.bytes += size
+
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeLocked(c.ll.Back())
+	}
+}
+
+// Len Return the entry count
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Purge Remove all entries
+func (c *LRUCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// removeLocked Remove an element, must be called with mu held
+func (c *LRUCache) removeLocked(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*lruEntry)
+	delete(c.items, entry.key)
+	c.bytes -= int64(len(entry.key) + len(entry.value))
+}
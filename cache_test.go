@@ -0,0 +1,73 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestCacheServesRepeatedChecks(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithCache(xiangxinai.CacheConfig{}))
+	ctx := context.Background()
+
+	result, err := client.CheckPrompt(ctx, "hello")
+	require.NoError(t, err)
+	assert.False(t, result.Cached)
+
+	result, err = client.CheckPrompt(ctx, "hello")
+	require.NoError(t, err)
+	assert.True(t, result.Cached)
+	assert.Equal(t, xiangxinai.ActionPass, result.SuggestAction)
+	assert.Equal(t, 1, server.RequestCount())
+
+	// The user ID is part of the key
+	_, err = client.CheckPrompt(ctx, "hello", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, server.RequestCount())
+}
+
+func TestCacheSkipsRiskyResponses(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithCache(xiangxinai.CacheConfig{}))
+	server.OnCategory(xiangxinai.DimensionCompliance, "violence", `fight`)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := client.CheckPrompt(ctx, "a fight scene")
+		require.NoError(t, err)
+		assert.False(t, result.Cached)
+	}
+	assert.Equal(t, 2, server.RequestCount())
+}
+
+func TestCacheIsScopedToAPIKeyAndBaseURL(t *testing.T) {
+	shared := xiangxinai.WithCache(xiangxinai.CacheConfig{Cache: xiangxinai.NewLRUCache(100, 1<<20)})
+	client, server := xiangxintest.NewClient(t, shared)
+	otherKey, err := xiangxinai.NewClient("other-api-key", xiangxinai.WithBaseURL(server.URL), xiangxinai.WithMaxRetries(0), shared)
+	require.NoError(t, err)
+	otherServer := xiangxintest.NewServer()
+	t.Cleanup(otherServer.Close)
+	otherURL, err := otherServer.Client(shared)
+	require.NoError(t, err)
+	sameScope, err := server.Client(shared)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = client.CheckPrompt(ctx, "hello")
+	require.NoError(t, err)
+	for _, other := range []*xiangxinai.Client{otherKey, otherURL} {
+		result, err := other.CheckPrompt(ctx, "hello")
+		require.NoError(t, err)
+		assert.False(t, result.Cached)
+	}
+	assert.Equal(t, 2, server.RequestCount())
+	assert.Equal(t, 1, otherServer.RequestCount())
+
+	result, err := sameScope.CheckPrompt(ctx, "hello")
+	require.NoError(t, err)
+	assert.True(t, result.Cached)
+}
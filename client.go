@@ -2,14 +2,10 @@ package xiangxinai
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
-	"io"
-	"math"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -26,6 +22,9 @@ const (
 	DefaultMaxRetries = 3
 	// UserAgent User agent
 	UserAgent = "xiangxinai-go/2.6.2"
+
+	// maxContentLength Maximum content length of a single message (characters)
+	maxContentLength = 1000000
 )
 
 // Client Xiangxin AI Guardrails client - Context-aware AI guardrail based on LLM
@@ -35,8 +34,11 @@ const (
 //
 // Example usage:
 //
-//	client := xiangxinai.NewClient("your-api-key")
-//	
+//	client, err := xiangxinai.NewClient("your-api-key")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
 //	// Check user input
 //	result, err := client.CheckPrompt(context.Background(), "用户问题")
 //	if err != nil {
@@ -61,73 +63,156 @@ const (
 //	fmt.Println(result.OverallRiskLevel) // "high_risk/medium_risk/low_risk/no_risk"
 //	fmt.Println(result.SuggestAction)    // "pass/reject/replace"
 type Client struct {
-	client     *resty.Client
-	maxRetries int
-}
-
-// NewClient Create new client, using default configuration
-func NewClient(apiKey string) *Client {
-	return NewClientWithConfig(&ClientConfig{
-		APIKey:     apiKey,
-		BaseURL:    DefaultBaseURL,
-		Timeout:    DefaultTimeout,
-		MaxRetries: DefaultMaxRetries,
-	})
+	client      *resty.Client
+	retryPolicy RetryPolicy
+
+	breaker          *circuitBreaker
+	failureMode      FailureMode
+	failClosedAnswer string
+
+	policy     *Policy
+	cache      *responseCache
+	cacheScope string // Hash of the API key and base URL, part of cache and coalescing keys
+	coalescer  *coalescer
+	limiter    *rateLimiter
+
+	batchConfig      BatchConfig
+	batchUnsupported int32 // Set once the server rejected the batch endpoint
+
+	imageConfig ImageConfig
+	telemetry   *telemetry    // OpenTelemetry instrumentation, nil if disabled
+	log         *clientLogger // Request logger, nil if disabled
+	redactor    *Redactor     // Local redaction of request content, nil if disabled
+
+	handler Next // Interceptor chain ending in check
+
+	modelsMu      sync.Mutex
+	models        ModelList // Model list cached for model discovery
+	modelsErr     error
+	modelsFetched time.Time
+	modelsRefresh chan struct{} // Closed when the in-flight model list fetch completes, nil if none
 }
 
-// NewClientWithConfig Create new client, using custom configuration
-func NewClientWithConfig(config *ClientConfig) *Client {
-	if config.APIKey == "" {
-		panic("API key cannot be empty")
+// NewClient Create new client
+//
+// Parameters:
+//   - apiKey: API key (cannot be empty)
+//   - opts: Optional client options, e.g. WithBaseURL, WithTimeout, WithHTTPClient
+//
+// Possible error types:
+//   - ValidationError: Invalid API key or options
+func NewClient(apiKey string, opts ...Option) (*Client, error) {
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, NewValidationError("API key cannot be empty")
 	}
-	
-	baseURL := config.BaseURL
-	if baseURL == "" {
-		baseURL = DefaultBaseURL
+
+	options := defaultClientOptions()
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(options); err != nil {
+			return nil, err
+		}
 	}
-	baseURL = strings.TrimSuffix(baseURL, "/")
-	
-	timeout := config.Timeout
-	if timeout <= 0 {
-		timeout = DefaultTimeout
+
+	httpClient, err := options.buildHTTPClient()
+	if err != nil {
+		return nil, err
 	}
-	
-	maxRetries := config.MaxRetries
-	if maxRetries < 0 {
-		maxRetries = DefaultMaxRetries
+
+	client := resty.NewWithClient(httpClient)
+	client.SetBaseURL(strings.TrimSuffix(options.baseURL, "/"))
+	for key, value := range options.headers {
+		client.SetHeader(key, value)
 	}
-	
-	client := resty.New()
-	client.SetBaseURL(baseURL)
-	client.SetTimeout(time.Duration(timeout) * time.Second)
-	client.SetHeader("Authorization", "Bearer "+config.APIKey)
+	client.SetHeader("Authorization", "Bearer "+apiKey)
 	client.SetHeader("Content-Type", "application/json")
-	client.SetHeader("User-Agent", UserAgent)
-	
-	return &Client{
-		client:     client,
-		maxRetries: maxRetries,
+	client.SetHeader("User-Agent", options.userAgent())
+
+	retryPolicy := options.retryPolicy
+	if retryPolicy == nil {
+		retryPolicy = NewDefaultRetryPolicy(options.maxRetries)
+	}
+
+	c := &Client{
+		client:           client,
+		retryPolicy:      retryPolicy,
+		failureMode:      options.failureMode,
+		failClosedAnswer: options.failClosedAnswer,
+		policy:           options.policy,
+		batchConfig:      options.batchConfig,
+		imageConfig:      options.imageConfig,
+		cacheScope:       cacheScope(apiKey, client.BaseURL),
+	}
+	if options.cacheConfig != nil {
+		c.cache = newResponseCache(*options.cacheConfig, c.cacheScope)
+	}
+	if options.rateLimit != nil {
+		c.limiter = newRateLimiter(*options.rateLimit)
+	}
+	if options.coalesce {
+		c.coalescer = newCoalescer()
+	}
+	if options.logConfig != nil {
+		c.log = newClientLogger(*options.logConfig, apiKey)
+	}
+	if options.breakerConfig != nil {
+		config := *options.breakerConfig
+		if c.log != nil {
+			onStateChange := config.OnStateChange
+			config.OnStateChange = func(from, to CircuitState) {
+				c.log.circuitStateChanged(from, to)
+				if onStateChange != nil {
+					onStateChange(from, to)
+				}
+			}
+		}
+		c.breaker = newCircuitBreaker(config, c.healthProbe)
+	}
+	if options.redaction != nil {
+		c.redactor = NewRedactor(options.redaction.Detectors...)
+	}
+	if options.telemetry != nil {
+		if c.telemetry, err = newTelemetry(*options.telemetry); err != nil {
+			return nil, err
+		}
 	}
+	// Built last, as the chain depends on the telemetry, logger, policy, redactor and cache set up above
+	c.handler = c.buildHandler(options.interceptors, options.batchInterceptors)
+	return c, nil
+}
+
+// NewClientWithConfig Create new client, using custom configuration
+//
+// Deprecated: Use NewClient with options instead. NewClientWithConfig panics
+// if the configuration is invalid, e.g. the API key is empty.
+func NewClientWithConfig(config *ClientConfig) *Client {
+	client, err := NewClient(config.APIKey, config.Options()...)
+	if err != nil {
+		panic(err)
+	}
+	return client
 }
 
 // createSafeResponse Create safe response
 func (c *Client) createSafeResponse() *GuardrailResponse {
-	return &GuardrailResponse{
+	return c.applyPolicy(&GuardrailResponse{
 		ID: "guardrails-safe-default",
 		Result: &GuardrailResult{
 			Compliance: &ComplianceResult{
-				RiskLevel:  "no_risk",
+				RiskLevel:  NoRisk,
 				Categories: []string{},
 			},
 			Security: &SecurityResult{
-				RiskLevel:  "no_risk",
+				RiskLevel:  NoRisk,
 				Categories: []string{},
 			},
 		},
-		OverallRiskLevel: "no_risk",
-		SuggestAction:    "pass",
+		OverallRiskLevel: NoRisk,
+		SuggestAction:    ActionPass,
 		SuggestAnswer:    nil,
-	}
+	})
 }
 
 // CheckPrompt Check user input safety
@@ -172,18 +257,29 @@ func (c *Client) createSafeResponse() *GuardrailResponse {
 //	fmt.Println(result.SuggestAction)    // "pass"
 //	fmt.Println(result.Result.Compliance.RiskLevel) // "no_risk"
 func (c *Client) CheckPrompt(ctx context.Context, content string, userID ...string) (*GuardrailResponse, error) {
+	return c.CheckPromptWithModel(ctx, content, DefaultModel, userID...)
+}
+
+// CheckPromptWithModel Check user input safety, specify model
+//
+// The /guardrails/input endpoint always uses the server's model, so a model
+// other than DefaultModel is sent to /guardrails as a single user message.
+func (c *Client) CheckPromptWithModel(ctx context.Context, content, model string, userID ...string) (*GuardrailResponse, error) {
 	// If content is an empty string, return no risk
 	if strings.TrimSpace(content) == "" {
 		return c.createSafeResponse(), nil
 	}
+	if model != "" && model != DefaultModel {
+		return c.CheckConversationWithModel(ctx, []*Message{NewMessage("user", content)}, model, userID...)
+	}
 
-	requestData := map[string]interface{}{
-		"input": strings.TrimSpace(content),
+	requestData := &InputRequest{
+		Input: strings.TrimSpace(content),
 	}
 
 	// Add optional userID parameter
-	if len(userID) > 0 && userID[0] != "" {
-		requestData["xxai_app_user_id"] = userID[0]
+	if len(userID) > 0 {
+		requestData.UserID = userID[0]
 	}
 
 	return c.makeRequestWithData(ctx, "POST", "/guardrails/input", requestData)
@@ -226,41 +322,12 @@ func (c *Client) CheckConversationWithModel(ctx context.Context, messages []*Mes
 		return nil, NewValidationError("messages cannot be empty")
 	}
 	
-	// Validate message format
-	var validatedMessages []*Message
-	allEmpty := true // Mark whether all content are empty
-	
-	for _, msg := range messages {
-		if msg == nil {
-			return nil, NewValidationError("message cannot be nil")
-		}
-		
-		if msg.Role != "user" && msg.Role != "system" && msg.Role != "assistant" {
-			return nil, NewValidationError("message role must be one of: user, system, assistant")
-		}
-		
-		if len(msg.Content) > 1000000 {
-			return nil, NewValidationError("content too long (max 1000000 characters)")
-		}
-		
-		content := strings.TrimSpace(msg.Content)
-		// Check if there is non-empty content
-		if content != "" {
-			allEmpty = false
-			// Only add non-empty messages to validatedMessages
-			validatedMessages = append(validatedMessages, &Message{
-				Role:    msg.Role,
-				Content: content,
-			})
-		}
+	validatedMessages, err := validateMessages(messages)
+	if err != nil {
+		return nil, err
 	}
-	
+
 	// If all messages' content are empty, return no risk
-	if allEmpty {
-		return c.createSafeResponse(), nil
-	}
-	
-	// Ensure at least one message
 	if len(validatedMessages) == 0 {
 		return c.createSafeResponse(), nil
 	}
@@ -315,53 +382,23 @@ func (c *Client) CheckResponseCtx(ctx context.Context, prompt, response string,
 		return c.createSafeResponse(), nil
 	}
 
-	requestData := map[string]interface{}{
-		"input":  strings.TrimSpace(prompt),
-		"output": strings.TrimSpace(response),
+	requestData := &OutputRequest{
+		Input:  strings.TrimSpace(prompt),
+		Output: strings.TrimSpace(response),
 	}
 
 	// Add optional userID parameter
-	if len(userID) > 0 && userID[0] != "" {
-		requestData["xxai_app_user_id"] = userID[0]
+	if len(userID) > 0 {
+		requestData.UserID = userID[0]
 	}
 
 	return c.makeRequestWithData(ctx, "POST", "/guardrails/output", requestData)
 }
 
-// encodeBase64FromPath Encode image to base64 format
-func (c *Client) encodeBase64FromPath(imagePath string) (string, error) {
-	if strings.HasPrefix(imagePath, "http://") || strings.HasPrefix(imagePath, "https://") {
-		// Get image from URL
-		resp, err := http.Get(imagePath)
-		if err != nil {
-			return "", fmt.Errorf("failed to fetch image from URL: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("failed to fetch image: status %d", resp.StatusCode)
-		}
-
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to read image data: %w", err)
-		}
-
-		return base64.StdEncoding.EncodeToString(data), nil
-	}
-
-	// Read image from local file
-	data, err := os.ReadFile(imagePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read image file: %w", err)
-	}
-
-	return base64.StdEncoding.EncodeToString(data), nil
-}
-
 // CheckPromptImage Check text prompt and image safety - multi-modal detection
 //
-// Combine text semantics and image content for safety detection.
+// Combine text semantics and image content for safety detection. The model
+// is the image model discovered by ImageModel.
 //
 // Parameters:
 //   - ctx: Context
@@ -386,7 +423,7 @@ func (c *Client) encodeBase64FromPath(imagePath string) (string, error) {
 //	}
 //	fmt.Println(result.OverallRiskLevel)
 func (c *Client) CheckPromptImage(ctx context.Context, prompt, image string, userID ...string) (*GuardrailResponse, error) {
-	return c.CheckPromptImageWithModel(ctx, prompt, image, "Xiangxin-Guardrails-VL", userID...)
+	return c.CheckPromptImageWithModel(ctx, prompt, image, c.ImageModel(ctx), userID...)
 }
 
 // CheckPromptImageWithModel Check text prompt and image safety, specify model
@@ -394,57 +431,13 @@ func (c *Client) CheckPromptImageWithModel(ctx context.Context, prompt, image, m
 	if image == "" {
 		return nil, NewValidationError("image path cannot be empty")
 	}
-
-	// Encode image
-	imageBase64, err := c.encodeBase64FromPath(image)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, NewValidationError(fmt.Sprintf("image file not found: %s", image))
-		}
-		return nil, NewXiangxinAIError(fmt.Sprintf("failed to encode image: %v", err), err)
-	}
-
-	// Build message content
-	content := []interface{}{}
-	if strings.TrimSpace(prompt) != "" {
-		content = append(content, map[string]string{
-			"type": "text",
-			"text": strings.TrimSpace(prompt),
-		})
-	}
-	content = append(content, map[string]interface{}{
-		"type": "image_url",
-		"image_url": map[string]string{
-			"url": fmt.Sprintf("data:image/jpeg;base64,%s", imageBase64),
-		},
-	})
-
-	messages := []*Message{
-		{
-			Role:    "user",
-			Content: content,
-		},
-	}
-
-	request := &GuardrailRequest{
-		Model:    model,
-		Messages: messages,
-	}
-
-	// Add optional userID parameter
-	if len(userID) > 0 && userID[0] != "" {
-		if request.ExtraBody == nil {
-			request.ExtraBody = make(map[string]interface{})
-		}
-		request.ExtraBody["xxai_app_user_id"] = userID[0]
-	}
-
-	return c.makeRequest(ctx, "POST", "/guardrails", request)
+	return c.CheckImagesWithModel(ctx, prompt, []ImageInput{ImageFromPath(image)}, model, userID...)
 }
 
 // CheckPromptImages Check text prompt and multiple images safety - multi-modal detection
 //
-// Combine text semantics and multiple image content for safety detection.
+// Combine text semantics and multiple image content for safety detection. The
+// model is the image model discovered by ImageModel.
 //
 // Parameters:
 //   - ctx: Context
@@ -464,47 +457,53 @@ func (c *Client) CheckPromptImageWithModel(ctx context.Context, prompt, image, m
 //	}
 //	fmt.Println(result.OverallRiskLevel)
 func (c *Client) CheckPromptImages(ctx context.Context, prompt string, images []string, userID ...string) (*GuardrailResponse, error) {
-	return c.CheckPromptImagesWithModel(ctx, prompt, images, "Xiangxin-Guardrails-VL", userID...)
+	return c.CheckPromptImagesWithModel(ctx, prompt, images, c.ImageModel(ctx), userID...)
 }
 
 // CheckPromptImagesWithModel Check text prompt and multiple images safety, specify model
 func (c *Client) CheckPromptImagesWithModel(ctx context.Context, prompt string, images []string, model string, userID ...string) (*GuardrailResponse, error) {
+	inputs := make([]ImageInput, len(images))
+	for i, image := range images {
+		inputs[i] = ImageFromPath(image)
+	}
+	return c.CheckImagesWithModel(ctx, prompt, inputs, model, userID...)
+}
+
+// CheckImages Check text prompt and images from any source - multi-modal detection
+//
+// Images can be local files, raw bytes, readers, data URLs, or http(s) URLs
+// that are either passed to the server or fetched and inlined by the client.
+// Inlined images are labelled with their sniffed MIME type and checked
+// against the limits of WithImageConfig. The model is the image model
+// discovered by ImageModel.
+//
+// Possible error types:
+//   - ValidationError: No images, unsupported image format or image too large
+//
+// Example:
+//
+//	result, err := client.CheckImages(ctx, "Is this image safe?", []xiangxinai.ImageInput{
+//		xiangxinai.ImageFromBytes(pngData),
+//		xiangxinai.ImageFromURL("https://example.com/image.webp"),
+//	})
+func (c *Client) CheckImages(ctx context.Context, prompt string, images []ImageInput, userID ...string) (*GuardrailResponse, error) {
+	return c.CheckImagesWithModel(ctx, prompt, images, c.ImageModel(ctx), userID...)
+}
+
+// CheckImagesWithModel Check text prompt and images from any source, specify model
+func (c *Client) CheckImagesWithModel(ctx context.Context, prompt string, images []ImageInput, model string, userID ...string) (*GuardrailResponse, error) {
 	if len(images) == 0 {
 		return nil, NewValidationError("images list cannot be empty")
 	}
 
 	// Build message content
-	content := []interface{}{}
-	if strings.TrimSpace(prompt) != "" {
-		content = append(content, map[string]string{
-			"type": "text",
-			"text": strings.TrimSpace(prompt),
-		})
-	}
-
-	// Encode all images
-	for _, imagePath := range images {
-		imageBase64, err := c.encodeBase64FromPath(imagePath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, NewValidationError(fmt.Sprintf("image file not found: %s", imagePath))
-			}
-			return nil, NewXiangxinAIError(fmt.Sprintf("failed to encode image %s: %v", imagePath, err), err)
-		}
-
-		content = append(content, map[string]interface{}{
-			"type": "image_url",
-			"image_url": map[string]string{
-				"url": fmt.Sprintf("data:image/jpeg;base64,%s", imageBase64),
-			},
-		})
+	parts, err := c.imageParts(ctx, prompt, images)
+	if err != nil {
+		return nil, err
 	}
 
 	messages := []*Message{
-		{
-			Role:    "user",
-			Content: content,
-		},
+		NewMultimodalMessage("user", parts...),
 	}
 
 	request := &GuardrailRequest{
@@ -523,18 +522,11 @@ func (c *Client) CheckPromptImagesWithModel(ctx context.Context, prompt string,
 	return c.makeRequest(ctx, "POST", "/guardrails", request)
 }
 
-// HealthCheck Check API service health status
+// HealthCheck Check API service health status, returning the raw response; see Health for the typed status
 func (c *Client) HealthCheck(ctx context.Context) (map[string]interface{}, error) {
-	resp, err := c.client.R().
-		SetContext(ctx).
-		Get("/guardrails/health")
-	
+	resp, err := c.doWithRetry(ctx, http.MethodGet, "/guardrails/health", nil)
 	if err != nil {
-		return nil, NewNetworkError("health check failed", err)
-	}
-	
-	if resp.IsError() {
-		return nil, c.handleErrorResponse(resp)
+		return nil, err
 	}
 	
 	var result map[string]interface{}
@@ -545,18 +537,11 @@ func (c *Client) HealthCheck(ctx context.Context) (map[string]interface{}, error
 	return result, nil
 }
 
-// GetModels Get available model list
+// GetModels Get available model list, returning the raw response; see ListModels for typed model information
 func (c *Client) GetModels(ctx context.Context) (map[string]interface{}, error) {
-	resp, err := c.client.R().
-		SetContext(ctx).
-		Get("/guardrails/models")
-	
+	resp, err := c.doWithRetry(ctx, http.MethodGet, "/guardrails/models", nil)
 	if err != nil {
-		return nil, NewNetworkError("get models failed", err)
-	}
-	
-	if resp.IsError() {
-		return nil, c.handleErrorResponse(resp)
+		return nil, err
 	}
 	
 	var result map[string]interface{}
@@ -567,122 +552,198 @@ func (c *Client) GetModels(ctx context.Context) (map[string]interface{}, error)
 	return result, nil
 }
 
+// validateMessages Validate messages, returning the normalized non-empty ones
+func validateMessages(messages []*Message) ([]*Message, error) {
+	var validatedMessages []*Message
+	for _, msg := range messages {
+		if msg == nil {
+			return nil, NewValidationError("message cannot be nil")
+		}
+
+		if msg.Role != "user" && msg.Role != "system" && msg.Role != "assistant" {
+			return nil, NewValidationError("message role must be one of: user, system, assistant")
+		}
+
+		content, nonEmpty, err := msg.Content.normalize()
+		if err != nil {
+			return nil, err
+		}
+		// Only keep non-empty messages
+		if nonEmpty {
+			validatedMessages = append(validatedMessages, &Message{
+				Role:    msg.Role,
+				Content: content,
+			})
+		}
+	}
+	return validatedMessages, nil
+}
+
 // makeRequest Send HTTP request
 func (c *Client) makeRequest(ctx context.Context, method, endpoint string, requestData *GuardrailRequest) (*GuardrailResponse, error) {
 	return c.makeRequestWithData(ctx, method, endpoint, requestData)
 }
 
-// makeRequestWithData Send HTTP request (generic version)
+// makeRequestWithData Send HTTP request (generic version), through the interceptor chain
 func (c *Client) makeRequestWithData(ctx context.Context, method, endpoint string, requestData interface{}) (*GuardrailResponse, error) {
-	var lastErr error
+	return c.handler(ctx, &Request{Method: method, Endpoint: endpoint, Body: requestData, Header: http.Header{}})
+}
+
+// check Send a check request through the coalescer and circuit breaker, the end of the interceptor chain
+func (c *Client) check(ctx context.Context, req *Request) (*GuardrailResponse, error) {
+	ctx = withRequestHeader(ctx, req.Header)
+	send := func(ctx context.Context) ([]byte, error) {
+		return c.send(ctx, req.Method, req.Endpoint, req.Body)
+	}
+	var body []byte
+	var err error
+	if c.coalescer != nil && req.Endpoint != batchEndpoint {
+		key, keyErr := req.cacheKey(c.cacheScope)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		body, err = c.coalescer.do(ctx, key, send)
+	} else {
+		body, err = send(ctx)
+	}
+	if req.Endpoint == batchEndpoint {
+		return c.batchResponse(ctx, req, body, err)
+	}
+	if err != nil {
+		return c.degrade(ctx, req.Endpoint, err)
+	}
+
+	var result GuardrailResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		if c.log != nil {
+			c.log.parseFailed(ctx, req.Endpoint, body, err)
+		}
+		return nil, NewXiangxinAIError("failed to parse response", err)
+	}
+	return &result, nil
+}
+
+// send Send HTTP request through the circuit breaker, returning the response body
+func (c *Client) send(ctx context.Context, method, endpoint string, requestData interface{}) ([]byte, error) {
+	if c.breaker != nil {
+		if err := c.breaker.allow(ctx); err != nil {
+			if c.log != nil {
+				c.log.circuitRejected(ctx, endpoint)
+			}
+			return nil, err
+		}
+	}
+
+	resp, err := c.doWithRetry(ctx, method, endpoint, requestData)
+	if c.breaker != nil {
+		c.breaker.record(ctx, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body(), nil
+}
+
+// doWithRetry Send HTTP request, retrying failed attempts according to the retry policy
+//
+// Returns the successful response, or the error of the last attempt.
+func (c *Client) doWithRetry(ctx context.Context, method, endpoint string, requestData interface{}) (*resty.Response, error) {
+	start := time.Now()
 	
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
-		resp, err := c.client.R().
-			SetContext(ctx).
-			SetBody(requestData).
-			Post(endpoint)
+	for attempt := 0; ; attempt++ {
+		req := c.client.R().SetContext(ctx)
+		if requestData != nil {
+			req.SetBody(requestData)
+		}
+		if header := requestHeader(ctx); header != nil {
+			req.SetHeaderMultiValues(header)
+		}
 		
-		if err != nil {
-			lastErr = NewNetworkError("request failed", err)
-			if attempt < c.maxRetries {
-				c.sleep(ctx, c.calculateBackoff(attempt))
-				continue
+		if c.limiter != nil {
+			if err := c.limiter.wait(ctx); err != nil {
+				return nil, err
 			}
-			return nil, lastErr
+		}
+
+		addAttempt(ctx)
+		resp, err := req.Execute(method, endpoint)
+		if err == nil && c.limiter != nil {
+			c.limiter.observe(resp.StatusCode(), resp.Header())
+		}
+		if err == nil && resp.IsSuccess() {
+			return resp, nil
 		}
 		
-		if resp.IsSuccess() {
-			var result GuardrailResponse
-			if err := json.Unmarshal(resp.Body(), &result); err != nil {
-				return nil, NewXiangxinAIError("failed to parse response", err)
-			}
-			return &result, nil
+		if err != nil {
+			err = NewNetworkError("request failed", err)
+		} else {
+			// Handle HTTP error status code
+			err = c.handleErrorResponse(resp)
 		}
 		
-		// Handle HTTP error status code
-		switch resp.StatusCode() {
-		case 401:
-			return nil, NewAuthenticationError("invalid API key")
-		case 422:
-			var errorResp map[string]interface{}
-			json.Unmarshal(resp.Body(), &errorResp)
-			detail := "validation error"
-			if d, ok := errorResp["detail"]; ok {
-				if s, ok := d.(string); ok {
-					detail = s
-				}
-			}
-			return nil, NewValidationError(fmt.Sprintf("validation error: %s", detail))
-		case 429:
-			if attempt < c.maxRetries {
-				// Exponential backoff retry
-				backoff := c.calculateBackoff(attempt)
-				c.sleep(ctx, backoff)
-				continue
+		retry := RetryAttempt{Attempt: attempt, Elapsed: time.Since(start), Err: err}
+		if ctx.Err() != nil || !c.retryPolicy.ShouldRetry(retry) {
+			if c.log != nil {
+				c.log.attemptFailed(ctx, endpoint, attempt, resp, err, false, 0)
 			}
-			return nil, NewRateLimitError("rate limit exceeded")
-		default:
-			errorMsg := string(resp.Body())
-			var errorResp map[string]interface{}
-			if json.Unmarshal(resp.Body(), &errorResp) == nil {
-				if detail, ok := errorResp["detail"].(string); ok {
-					errorMsg = detail
-				}
-			}
-			lastErr = NewXiangxinAIError(fmt.Sprintf("API request failed with status %d: %s", resp.StatusCode(), errorMsg), nil)
-			if attempt < c.maxRetries {
-				c.sleep(ctx, c.calculateBackoff(attempt))
-				continue
-			}
-			return nil, lastErr
+			return nil, err
+		}
+		delay := c.retryPolicy.NextDelay(retry)
+		if c.log != nil {
+			c.log.attemptFailed(ctx, endpoint, attempt, resp, err, true, delay)
+		}
+		if c.telemetry != nil {
+			c.telemetry.recordRetry(ctx, endpoint, retry, delay)
+		}
+		if sleepErr := c.sleep(ctx, delay); sleepErr != nil {
+			return nil, err
 		}
 	}
-	
-	return nil, lastErr
 }
 
 // handleErrorResponse Handle error response
+//
+// The returned error always wraps an *APIError with the response details.
 func (c *Client) handleErrorResponse(resp *resty.Response) error {
-	switch resp.StatusCode() {
-	case 401:
-		return NewAuthenticationError("invalid API key")
-	case 422:
-		var errorResp map[string]interface{}
-		json.Unmarshal(resp.Body(), &errorResp)
-		detail := "validation error"
-		if d, ok := errorResp["detail"]; ok {
-			if s, ok := d.(string); ok {
-				detail = s
-			}
-		}
-		return NewValidationError(fmt.Sprintf("validation error: %s", detail))
-	case 429:
-		return NewRateLimitError("rate limit exceeded")
-	default:
-		errorMsg := string(resp.Body())
-		var errorResp map[string]interface{}
-		if json.Unmarshal(resp.Body(), &errorResp) == nil {
-			if detail, ok := errorResp["detail"].(string); ok {
-				errorMsg = detail
-			}
-		}
-		return NewXiangxinAIError(fmt.Sprintf("API request failed with status %d: %s", resp.StatusCode(), errorMsg), nil)
-	}
+	return wrapAPIError(newAPIError(resp.StatusCode(), resp.Header(), resp.Body()))
 }
 
-// calculateBackoff Calculate exponential backoff waiting time
-func (c *Client) calculateBackoff(attempt int) time.Duration {
-	base := time.Second
-	backoff := time.Duration(math.Pow(2, float64(attempt))) * base
-	return backoff + time.Second
+// wrapAPIError Wrap an API error in the typed error of its status code
+func wrapAPIError(apiErr *APIError) error {
+	switch {
+	case apiErr.StatusCode == 401:
+		err := NewAuthenticationError("invalid API key")
+		err.Cause = apiErr
+		return err
+	case apiErr.StatusCode == 422:
+		err := NewValidationError("validation error")
+		err.Cause = apiErr
+		return err
+	case apiErr.StatusCode == 429:
+		err := NewRateLimitError("rate limit exceeded")
+		err.Cause = apiErr
+		return err
+	case apiErr.StatusCode >= 500:
+		err := NewServerError("server error")
+		err.Cause = apiErr
+		return err
+	default:
+		return apiErr
+	}
 }
 
-// sleep Wait for specified time, support context cancellation
-func (c *Client) sleep(ctx context.Context, duration time.Duration) {
+// sleep Wait for specified time, return the context error if cancelled first
+func (c *Client) sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	
 	select {
 	case <-ctx.Done():
-		return
-	case <-time.After(duration):
-		return
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,46 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestCheckPromptWithModel(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	ctx := context.Background()
+
+	_, err := client.CheckPromptWithModel(ctx, "hello", xiangxinai.DefaultModel, "user-1")
+	require.NoError(t, err)
+	request, ok := server.LastRequest()
+	require.True(t, ok)
+	assert.Equal(t, "/guardrails/input", request.Path)
+
+	// Other models are sent to /guardrails
+	_, err = client.CheckPromptWithModel(ctx, "hello", "Custom-Model", "user-1")
+	require.NoError(t, err)
+	request, ok = server.LastRequest()
+	require.True(t, ok)
+	assert.Equal(t, "/guardrails", request.Path)
+	assert.Equal(t, "Custom-Model", request.Model)
+	assert.Equal(t, "user-1", request.UserID)
+	require.Len(t, request.Messages, 1)
+	assert.Equal(t, "user", request.Messages[0].Role)
+	assert.Equal(t, "hello", request.Messages[0].Content.String())
+
+	server.Reset()
+	async := xiangxinai.NewAsyncClientWithClient(client, 2)
+	defer async.Close()
+	for result := range async.BatchCheckPromptsWithModel(ctx, []string{"a", "b"}, "Custom-Model") {
+		require.NoError(t, result.Error)
+	}
+	for _, request := range server.Requests() {
+		assert.Equal(t, "Custom-Model", request.Model)
+	}
+	assert.Equal(t, 2, server.RequestCount("/guardrails"))
+}
@@ -0,0 +1,82 @@
+// Command xiangxin-proxy runs an OpenAI-compatible reverse proxy that guards
+// chat completions with Xiangxin AI Guardrails.
+//
+// Usage:
+//
+//	XIANGXINAI_API_KEY=your-api-key OPENAI_API_KEY=sk-... \
+//		xiangxin-proxy -listen :8080 -upstream https://api.openai.com/v1
+//
+// Then point any OpenAI client at http://localhost:8080/v1.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/proxy"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "Listen address")
+	upstream := flag.String("upstream", "https://api.openai.com/v1", "Upstream OpenAI-compatible base URL")
+	upstreamKey := flag.String("upstream-key", os.Getenv("OPENAI_API_KEY"), "Upstream API key (default $OPENAI_API_KEY); if empty the client's Authorization header is forwarded")
+	apiKey := flag.String("api-key", os.Getenv("XIANGXINAI_API_KEY"), "Xiangxin AI API key (default $XIANGXINAI_API_KEY)")
+	baseURL := flag.String("base-url", xiangxinai.DefaultBaseURL, "Xiangxin AI API base URL")
+	model := flag.String("model", xiangxinai.DefaultModel, "Guardrail model for input checks")
+	policyFile := flag.String("policy", "", "Local policy file (.yaml or .json)")
+	failureMode := flag.String("failure-mode", "error", "Behavior when the guardrail is unavailable: error, open or closed")
+	skipOutput := flag.Bool("skip-output", false, "Skip output checks of completions")
+	timeout := flag.Duration("timeout", 120*time.Second, "Upstream request timeout")
+	flag.Parse()
+
+	opts := []xiangxinai.Option{
+		xiangxinai.WithBaseURL(*baseURL),
+		xiangxinai.WithUserAgentSuffix("xiangxin-proxy"),
+		xiangxinai.WithCircuitBreaker(xiangxinai.CircuitBreakerConfig{}),
+	}
+	switch *failureMode {
+	case "error":
+	case "open":
+		opts = append(opts, xiangxinai.WithFailureMode(xiangxinai.FailOpen))
+	case "closed":
+		opts = append(opts, xiangxinai.WithFailureMode(xiangxinai.FailClosed))
+	default:
+		log.Fatalf("invalid -failure-mode %q, must be error, open or closed", *failureMode)
+	}
+	if *policyFile != "" {
+		policy, err := xiangxinai.LoadPolicy(*policyFile)
+		if err != nil {
+			log.Fatalf("failed to load policy: %v", err)
+		}
+		opts = append(opts, xiangxinai.WithPolicy(policy))
+	}
+
+	client, err := xiangxinai.NewClient(*apiKey, opts...)
+	if err != nil {
+		log.Fatalf("failed to create guardrail client: %v", err)
+	}
+
+	p, err := proxy.New(proxy.Config{
+		Client:         client,
+		UpstreamURL:    *upstream,
+		UpstreamAPIKey: *upstreamKey,
+		HTTPClient:     &http.Client{Timeout: *timeout},
+		Model:          *model,
+		SkipOutput:     *skipOutput,
+	})
+	if err != nil {
+		log.Fatalf("failed to create proxy: %v", err)
+	}
+
+	log.Printf("xiangxin-proxy listening on %s, upstream %s", *listen, *upstream)
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Fatal(server.ListenAndServe())
+}
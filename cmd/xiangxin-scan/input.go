@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+// maxLineBytes Longest input line accepted
+const maxLineBytes = 16 << 20
+
+// fields Names of the record fields in JSONL objects and CSV headers
+type fields struct {
+	id       string
+	prompt   string
+	response string
+	messages string
+}
+
+// record Input record
+type record struct {
+	id       string
+	messages []*xiangxinai.Message
+	err      error // Malformed record, reported as the item's error
+}
+
+// recordReader Reads input records, returning io.EOF at the end of the input
+type recordReader interface {
+	next() (record, error)
+}
+
+// detectFormat Return the input format for the auto format
+func detectFormat(format, path string) string {
+	if format != "auto" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson", ".json":
+		return "jsonl"
+	case ".csv":
+		return "csv"
+	default:
+		return "text"
+	}
+}
+
+// newRecordReader Create reader of the given format
+func newRecordReader(r io.Reader, format string, f fields) (recordReader, error) {
+	switch format {
+	case "text":
+		return &textReader{scanner: newScanner(r)}, nil
+	case "jsonl":
+		return &jsonlReader{scanner: newScanner(r), fields: f}, nil
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		reader.ReuseRecord = true
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		columns := make(map[string]int, len(header))
+		for i, name := range header {
+			columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+		}
+		_, hasPrompt := columns[f.prompt]
+		_, hasMessages := columns[f.messages]
+		if !hasPrompt && !hasMessages {
+			return nil, fmt.Errorf("CSV header has neither a %q nor a %q column", f.prompt, f.messages)
+		}
+		return &csvReader{reader: reader, columns: columns, fields: f, row: 1}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be auto, jsonl, csv or text", format)
+	}
+}
+
+// newScanner Create line scanner accepting long lines
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	return scanner
+}
+
+// textReader Reads one prompt per line, skipping blank lines
+type textReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *textReader) next() (record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" {
+			continue
+		}
+		return record{id: strconv.Itoa(r.line), messages: promptMessages(text, "")}, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return record{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return record{}, io.EOF
+}
+
+// jsonlReader Reads one JSON value per line, skipping blank lines
+//
+// A line is a prompt string, an OpenAI messages array, or an object with a
+// prompt (and optional response) or messages field and an optional ID.
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	fields  fields
+	line    int
+}
+
+func (r *jsonlReader) next() (record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		rec := record{id: strconv.Itoa(r.line)}
+		if err := r.parse(data, &rec); err != nil {
+			rec.messages = nil
+			rec.err = fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return record{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return record{}, io.EOF
+}
+
+// parse Parse a JSONL line into rec
+func (r *jsonlReader) parse(data []byte, rec *record) error {
+	switch data[0] {
+	case '"':
+		var prompt string
+		if err := json.Unmarshal(data, &prompt); err != nil {
+			return err
+		}
+		rec.messages = promptMessages(prompt, "")
+		return nil
+	case '[':
+		return json.Unmarshal(data, &rec.messages)
+	case '{':
+	default:
+		return fmt.Errorf("expected a string, array or object")
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	if raw, ok := object[r.fields.id]; ok {
+		id, err := jsonScalar(raw)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", r.fields.id, err)
+		}
+		if id != "" {
+			rec.id = id
+		}
+	}
+	if raw, ok := object[r.fields.messages]; ok {
+		if err := json.Unmarshal(raw, &rec.messages); err != nil {
+			return fmt.Errorf("field %q: %w", r.fields.messages, err)
+		}
+		return nil
+	}
+
+	var prompt, response string
+	if raw, ok := object[r.fields.prompt]; ok {
+		if err := json.Unmarshal(raw, &prompt); err != nil {
+			return fmt.Errorf("field %q: %w", r.fields.prompt, err)
+		}
+	}
+	if raw, ok := object[r.fields.response]; ok {
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return fmt.Errorf("field %q: %w", r.fields.response, err)
+		}
+	}
+	if prompt == "" && response == "" {
+		return fmt.Errorf("object has no %q, %q or %q field", r.fields.prompt, r.fields.response, r.fields.messages)
+	}
+	rec.messages = promptMessages(prompt, response)
+	return nil
+}
+
+// csvReader Reads CSV rows with a header naming the ID, prompt, response or messages columns
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	fields  fields
+	row     int
+}
+
+func (r *csvReader) next() (record, error) {
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return record{}, io.EOF
+	}
+	r.row++
+	if err != nil {
+		if _, ok := err.(*csv.ParseError); ok {
+			return record{id: strconv.Itoa(r.row), err: err}, nil
+		}
+		return record{}, err
+	}
+
+	column := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	rec := record{id: strconv.Itoa(r.row)}
+	if id := strings.TrimSpace(column(r.fields.id)); id != "" {
+		rec.id = id
+	}
+	if messages := strings.TrimSpace(column(r.fields.messages)); messages != "" {
+		if err := json.Unmarshal([]byte(messages), &rec.messages); err != nil {
+			rec.messages = nil
+			rec.err = fmt.Errorf("row %d: column %q: %w", r.row, r.fields.messages, err)
+		}
+		return rec, nil
+	}
+	rec.messages = promptMessages(column(r.fields.prompt), column(r.fields.response))
+	return rec, nil
+}
+
+// promptMessages Build the messages of a prompt and optional response
+func promptMessages(prompt, response string) []*xiangxinai.Message {
+	messages := []*xiangxinai.Message{xiangxinai.NewMessage("user", prompt)}
+	if response != "" {
+		messages = append(messages, xiangxinai.NewMessage("assistant", response))
+	}
+	return messages
+}
+
+// jsonScalar Return a JSON string or number as a string
+func jsonScalar(raw json.RawMessage) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return string(bytes.TrimSpace(raw)), nil
+	default:
+		return "", fmt.Errorf("must be a string or number")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultFields = fields{id: "id", prompt: "prompt", response: "response", messages: "messages"}
+
+// readAll Read every record of the input
+func readAll(t *testing.T, input, format string) []record {
+	t.Helper()
+	reader, err := newRecordReader(strings.NewReader(input), format, defaultFields)
+	require.NoError(t, err)
+	var records []record
+	for {
+		rec, err := reader.next()
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, rec)
+	}
+}
+
+// contents Return the role and content of each message
+func contents(rec record) []string {
+	var out []string
+	for _, message := range rec.messages {
+		out = append(out, message.Role+": "+message.Content.String())
+	}
+	return out
+}
+
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, "jsonl", detectFormat("auto", "chats.JSONL"))
+	assert.Equal(t, "jsonl", detectFormat("auto", "chats.ndjson"))
+	assert.Equal(t, "csv", detectFormat("auto", "chats.csv"))
+	assert.Equal(t, "text", detectFormat("auto", "-"))
+	assert.Equal(t, "csv", detectFormat("csv", "chats.txt"))
+}
+
+func TestReadJSONL(t *testing.T) {
+	input := strings.Join([]string{
+		`"plain prompt"`,
+		`[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"}]`,
+		``,
+		`{"id": 42, "prompt": "question", "response": "answer"}`,
+		`{"id": "conv-1", "messages": [{"role": "user", "content": "from messages"}]}`,
+		`not json`,
+		`{"other": 1}`,
+		`{"prompt": 1}`,
+	}, "\n")
+	records := readAll(t, input, "jsonl")
+	require.Len(t, records, 7)
+
+	assert.Equal(t, "1", records[0].id)
+	assert.Equal(t, []string{"user: plain prompt"}, contents(records[0]))
+	assert.Equal(t, "2", records[1].id)
+	assert.Equal(t, []string{"user: hi", "assistant: hello"}, contents(records[1]))
+	assert.Equal(t, "42", records[2].id)
+	assert.Equal(t, []string{"user: question", "assistant: answer"}, contents(records[2]))
+	assert.Equal(t, "conv-1", records[3].id)
+	assert.Equal(t, []string{"user: from messages"}, contents(records[3]))
+	for _, rec := range records[:4] {
+		assert.NoError(t, rec.err)
+	}
+
+	// Malformed lines are returned as records with an error
+	for _, rec := range records[4:] {
+		assert.Error(t, rec.err)
+		assert.Nil(t, rec.messages)
+	}
+	assert.Contains(t, records[4].err.Error(), "line 6")
+	assert.Contains(t, records[5].err.Error(), `no "prompt", "response" or "messages" field`)
+	assert.Contains(t, records[6].err.Error(), `field "prompt"`)
+}
+
+func TestReadJSONLCustomFields(t *testing.T) {
+	reader, err := newRecordReader(strings.NewReader(`{"key": "k1", "q": "question", "a": "answer"}`), "jsonl",
+		fields{id: "key", prompt: "q", response: "a", messages: "messages"})
+	require.NoError(t, err)
+	rec, err := reader.next()
+	require.NoError(t, err)
+	assert.Equal(t, "k1", rec.id)
+	assert.Equal(t, []string{"user: question", "assistant: answer"}, contents(rec))
+}
+
+func TestReadCSV(t *testing.T) {
+	input := "\ufeffid, prompt ,response\n" +
+		"a,\"question, with comma\",answer\n" +
+		",only prompt\n" +
+		"c,\"multi\nline\",\n"
+	records := readAll(t, input, "csv")
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "a", records[0].id)
+	assert.Equal(t, []string{"user: question, with comma", "assistant: answer"}, contents(records[0]))
+	// Rows without an ID are numbered by row, counting the header
+	assert.Equal(t, "3", records[1].id)
+	assert.Equal(t, []string{"user: only prompt"}, contents(records[1]))
+	assert.Equal(t, "c", records[2].id)
+	assert.Equal(t, []string{"user: multi\nline"}, contents(records[2]))
+}
+
+func TestReadCSVMessages(t *testing.T) {
+	input := "id,messages\n" +
+		`1,"[{""role"":""user"",""content"":""hi""}]"` + "\n" +
+		"2,not json\n"
+	records := readAll(t, input, "csv")
+	require.Len(t, records, 2)
+	assert.Equal(t, []string{"user: hi"}, contents(records[0]))
+	assert.NoError(t, records[0].err)
+	assert.Error(t, records[1].err)
+	assert.Contains(t, records[1].err.Error(), `row 3: column "messages"`)
+}
+
+func TestReadCSVRequiresColumns(t *testing.T) {
+	_, err := newRecordReader(strings.NewReader("id,text\n1,hello\n"), "csv", defaultFields)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `neither a "prompt" nor a "messages" column`)
+
+	_, err = newRecordReader(strings.NewReader(""), "csv", defaultFields)
+	assert.Error(t, err)
+}
+
+func TestReadText(t *testing.T) {
+	records := readAll(t, "first\n\n  second  \n\nthird", "text")
+	require.Len(t, records, 3)
+	assert.Equal(t, "1", records[0].id)
+	assert.Equal(t, []string{"user: first"}, contents(records[0]))
+	assert.Equal(t, "3", records[1].id)
+	assert.Equal(t, []string{"user: second"}, contents(records[1]))
+	assert.Equal(t, "5", records[2].id)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := newRecordReader(strings.NewReader(""), "xml", defaultFields)
+	assert.Error(t, err)
+}
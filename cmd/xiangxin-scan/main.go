@@ -0,0 +1,326 @@
+// Command xiangxin-scan moderates datasets offline with Xiangxin AI
+// Guardrails, e.g. training corpora and chat logs.
+//
+// Each input record is a prompt, a prompt/response pair or an OpenAI messages
+// array. Supported formats are JSONL (a string, a messages array, or an object
+// with prompt, response, messages and id fields), CSV with a header row naming
+// the same columns, and plain text with one prompt per line. Results are
+// written as JSONL in input order, with risk levels, categories and actions.
+//
+// Usage:
+//
+//	XIANGXINAI_API_KEY=your-api-key \
+//		xiangxin-scan -input chats.jsonl -output results.jsonl -concurrency 20 -fail-on high_risk
+//
+// When writing to a file, progress is checkpointed to <output>.checkpoint;
+// rerun with -resume to continue an interrupted scan.
+//
+// Exit codes: 0 on success, 1 on fatal errors, 2 if a record reached the
+// -fail-on risk level, 3 if some records failed to be checked, 130 if
+// interrupted.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+// Exit codes
+const (
+	exitOK          = 0
+	exitFatal       = 1
+	exitFailOn      = 2
+	exitItemErrors  = 3
+	exitInterrupted = 130
+)
+
+// readError Error reading the input, which ends the scan
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string {
+	return fmt.Sprintf("failed to read input: %v", e.err)
+}
+
+func (e *readError) Unwrap() error {
+	return e.err
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run Run the scan with the given arguments and streams, returning the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("xiangxin-scan", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	input := flags.String("input", "-", "Input file, - for stdin")
+	output := flags.String("output", "-", "Output JSONL file, - for stdout")
+	format := flags.String("format", "auto", "Input format: auto (by extension), jsonl, csv or text")
+	idField := flags.String("id-field", "id", "JSONL field or CSV column of the record ID")
+	promptField := flags.String("prompt-field", "prompt", "JSONL field or CSV column of the prompt")
+	responseField := flags.String("response-field", "response", "JSONL field or CSV column of the response")
+	messagesField := flags.String("messages-field", "messages", "JSONL field or CSV column of the OpenAI messages array")
+	apiKey := flags.String("api-key", os.Getenv("XIANGXINAI_API_KEY"), "Xiangxin AI API key (default $XIANGXINAI_API_KEY)")
+	baseURL := flags.String("base-url", xiangxinai.DefaultBaseURL, "Xiangxin AI API base URL")
+	model := flags.String("model", xiangxinai.DefaultModel, "Guardrail model")
+	concurrency := flags.Int("concurrency", 10, "Requests in flight")
+	rateLimit := flags.Float64("rate-limit", 0, "Maximum requests per second, 0 for unlimited")
+	timeout := flags.Duration("timeout", 30*time.Second, "Request timeout")
+	policyFile := flags.String("policy", "", "Local policy file (.yaml or .json) deciding the action")
+	checkpointFile := flags.String("checkpoint", "", "Checkpoint file (default <output>.checkpoint)")
+	checkpointEvery := flags.Int("checkpoint-every", 1000, "Records between checkpoints")
+	resume := flags.Bool("resume", false, "Resume from the checkpoint")
+	summaryFile := flags.String("summary", "", "Write the summary report as JSON to this file")
+	failOn := flags.String("fail-on", "", "Exit with code 2 if any record reaches this risk level, e.g. high_risk")
+	quiet := flags.Bool("quiet", false, "Do not print the summary to stderr")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitFatal
+	}
+
+	logger := log.New(stderr, "xiangxin-scan: ", 0)
+
+	var failLevel xiangxinai.RiskLevel
+	if *failOn != "" {
+		level, err := xiangxinai.ParseRiskLevel(*failOn)
+		if err != nil {
+			logger.Printf("invalid -fail-on: %v", err)
+			return exitFatal
+		}
+		failLevel = level
+	}
+	if *checkpointFile == "" && *output != "-" {
+		*checkpointFile = *output + ".checkpoint"
+	}
+	if *resume && *checkpointFile == "" {
+		logger.Print("-resume requires -output to be a file or -checkpoint to be set")
+		return exitFatal
+	}
+
+	// Restore progress
+	cp := &checkpoint{Input: *input, Output: *output, Summary: newSummary()}
+	if *resume {
+		loaded, err := loadCheckpoint(*checkpointFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			logger.Printf("no checkpoint at %s, starting from the beginning", *checkpointFile)
+		case err != nil:
+			logger.Print(err)
+			return exitFatal
+		case loaded.Input != *input || loaded.Output != *output:
+			logger.Printf("checkpoint %s is for -input %s -output %s", *checkpointFile, loaded.Input, loaded.Output)
+			return exitFatal
+		default:
+			cp = loaded
+		}
+	}
+
+	// Open the input, skipping processed records
+	in := stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			logger.Print(err)
+			return exitFatal
+		}
+		defer f.Close()
+		in = f
+	}
+	reader, err := newRecordReader(in, detectFormat(*format, *input), fields{
+		id:       *idField,
+		prompt:   *promptField,
+		response: *responseField,
+		messages: *messagesField,
+	})
+	if err != nil {
+		logger.Print(err)
+		return exitFatal
+	}
+	for i := 0; i < cp.Processed; i++ {
+		if _, err := reader.next(); err != nil {
+			logger.Printf("failed to skip %d processed records: %v", cp.Processed, err)
+			return exitFatal
+		}
+	}
+
+	// Open the output, dropping lines written after the checkpoint
+	out := &countingWriter{w: stdout, n: cp.OutputOffset}
+	if *output != "-" {
+		mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if cp.Processed > 0 {
+			mode = os.O_WRONLY | os.O_CREATE
+		}
+		f, err := os.OpenFile(*output, mode, 0o644)
+		if err == nil && cp.Processed > 0 {
+			if err = f.Truncate(cp.OutputOffset); err == nil {
+				_, err = f.Seek(cp.OutputOffset, io.SeekStart)
+			}
+		}
+		if err != nil {
+			logger.Print(err)
+			return exitFatal
+		}
+		defer f.Close()
+		out.w = f
+	}
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	saveCheckpoint := func() error {
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if *checkpointFile == "" {
+			return nil
+		}
+		cp.OutputOffset = out.n
+		return cp.save(*checkpointFile)
+	}
+
+	// Create the client
+	opts := []xiangxinai.Option{
+		xiangxinai.WithBaseURL(*baseURL),
+		xiangxinai.WithTimeout(*timeout),
+		xiangxinai.WithUserAgentSuffix("xiangxin-scan"),
+	}
+	if *rateLimit > 0 {
+		opts = append(opts, xiangxinai.WithRateLimit(xiangxinai.RateLimitConfig{QPS: *rateLimit}))
+	}
+	if *policyFile != "" {
+		policy, err := xiangxinai.LoadPolicy(*policyFile)
+		if err != nil {
+			logger.Printf("failed to load policy: %v", err)
+			return exitFatal
+		}
+		opts = append(opts, xiangxinai.WithPolicy(policy))
+	}
+	client, err := xiangxinai.NewClient(*apiKey, opts...)
+	if err != nil {
+		logger.Printf("failed to create guardrail client: %v", err)
+		return exitFatal
+	}
+	asyncClient := xiangxinai.NewAsyncClientWithClient(client, *concurrency)
+	defer asyncClient.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Malformed records are passed through without messages and reported by index
+	var mu sync.Mutex
+	recordErrs := make(map[int]error)
+	index := 0
+	next := func() (xiangxinai.BatchItem, error) {
+		rec, err := reader.next()
+		if err == io.EOF {
+			return xiangxinai.BatchItem{}, err
+		}
+		if err != nil {
+			return xiangxinai.BatchItem{}, &readError{err: err}
+		}
+		if rec.err != nil {
+			mu.Lock()
+			recordErrs[index] = rec.err
+			mu.Unlock()
+		}
+		index++
+		return xiangxinai.BatchItem{ID: rec.id, Messages: rec.messages, Model: *model}, nil
+	}
+
+	results := asyncClient.PipelineFunc(ctx, next, xiangxinai.PipelineConfig{
+		MaxInFlight: *concurrency,
+		Ordered:     true,
+	})
+	base := cp.Processed
+	for r := range results {
+		var readErr *readError
+		if errors.As(r.Err, &readErr) {
+			if err := saveCheckpoint(); err != nil {
+				logger.Printf("failed to save checkpoint: %v", err)
+			}
+			logger.Print(readErr)
+			return exitFatal
+		}
+
+		itemErr := r.Err
+		mu.Lock()
+		if err, ok := recordErrs[r.Index]; ok {
+			itemErr = err
+			delete(recordErrs, r.Index)
+		}
+		mu.Unlock()
+
+		rec := newOutputRecord(base+r.Index, r.ID, r.Result, itemErr)
+		if err := encoder.Encode(rec); err != nil {
+			logger.Printf("failed to write output: %v", err)
+			return exitFatal
+		}
+		cp.Summary.add(rec)
+		cp.Processed++
+
+		if *checkpointEvery > 0 && cp.Processed%*checkpointEvery == 0 {
+			if err := saveCheckpoint(); err != nil {
+				logger.Printf("failed to save checkpoint: %v", err)
+				return exitFatal
+			}
+		}
+	}
+
+	if err := saveCheckpoint(); err != nil {
+		logger.Printf("failed to save checkpoint: %v", err)
+		return exitFatal
+	}
+	if *summaryFile != "" {
+		data, _ := json.MarshalIndent(cp.Summary, "", "  ")
+		if err := os.WriteFile(*summaryFile, append(data, '\n'), 0o644); err != nil {
+			logger.Printf("failed to write summary: %v", err)
+			return exitFatal
+		}
+	}
+	if !*quiet {
+		cp.Summary.print(stderr)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		logger.Printf("interrupted after %d records, rerun with -resume to continue", cp.Processed)
+		return exitInterrupted
+	case failLevel != "" && cp.Summary.countAtLeast(failLevel) > 0:
+		logger.Printf("%d records reached %s", cp.Summary.countAtLeast(failLevel), failLevel)
+		return exitFailOn
+	case cp.Summary.Errors > 0:
+		logger.Printf("%d records failed to be checked", cp.Summary.Errors)
+		return exitItemErrors
+	default:
+		return exitOK
+	}
+}
+
+// countingWriter Writer counting the bytes written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
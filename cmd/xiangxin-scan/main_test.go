@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// newScanServer Start a mock server flagging content containing "bad"
+func newScanServer(t *testing.T) *xiangxintest.Server {
+	server := xiangxintest.NewServer()
+	t.Cleanup(server.Close)
+	server.OnContent("bad", xiangxintest.Verdict{Categories: []string{"Violent Crime"}})
+	return server
+}
+
+// scan Run the command against server, returning the exit code, stdout and stderr
+func scan(t *testing.T, server *xiangxintest.Server, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	args = append([]string{"-api-key", xiangxintest.DefaultAPIKey, "-base-url", server.URL}, args...)
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// readOutput Parse the JSONL output
+func readOutput(t *testing.T, data string) []outputRecord {
+	t.Helper()
+	var records []outputRecord
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		var rec outputRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec), scanner.Text())
+		records = append(records, rec)
+	}
+	return records
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestScanStdin(t *testing.T) {
+	server := newScanServer(t)
+	code, stdout, stderr := scan(t, server, "hello\nsomething bad\n\nbye\n", "-format", "text")
+	assert.Equal(t, exitOK, code, stderr)
+
+	records := readOutput(t, stdout)
+	require.Len(t, records, 3)
+	for i, rec := range records {
+		assert.Equal(t, i, rec.Index)
+		assert.Empty(t, rec.Error)
+	}
+	assert.Equal(t, []string{"1", "2", "4"}, []string{records[0].ID, records[1].ID, records[2].ID})
+	assert.Equal(t, xiangxinai.NoRisk, records[0].RiskLevel)
+	assert.Equal(t, xiangxinai.HighRisk, records[1].RiskLevel)
+	assert.Equal(t, xiangxinai.ActionReject, records[1].Action)
+	assert.Equal(t, []string{"Violent Crime"}, records[1].Categories)
+	require.NotNil(t, records[1].Answer)
+	assert.Equal(t, xiangxintest.DefaultAnswer, *records[1].Answer)
+
+	// The summary is printed to stderr unless -quiet is set
+	assert.Contains(t, stderr, "Records:  3")
+	assert.Contains(t, stderr, "  high_risk  1")
+	_, _, stderr = scan(t, server, "hello\n", "-format", "text", "-quiet")
+	assert.Empty(t, stderr)
+}
+
+func TestScanSummaryFile(t *testing.T) {
+	server := newScanServer(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "chats.jsonl")
+	writeFile(t, input, `{"id": "a", "prompt": "hello"}`+"\n"+`{"id": "b", "prompt": "bad", "response": "ok"}`+"\n"+"not json\n")
+	summaryFile := filepath.Join(dir, "summary.json")
+
+	code, stdout, stderr := scan(t, server, "", "-input", input, "-summary", summaryFile, "-quiet")
+	assert.Equal(t, exitItemErrors, code, stderr)
+	assert.Contains(t, stderr, "1 records failed to be checked")
+
+	records := readOutput(t, stdout)
+	require.Len(t, records, 3)
+	assert.Equal(t, "a", records[0].ID)
+	assert.Equal(t, "b", records[1].ID)
+	assert.Contains(t, records[2].Error, "line 3")
+
+	var s summary
+	require.NoError(t, json.Unmarshal([]byte(readFile(t, summaryFile)), &s))
+	assert.Equal(t, 3, s.Total)
+	assert.Equal(t, 1, s.Errors)
+	assert.Equal(t, map[string]int{"no_risk": 1, "high_risk": 1}, s.RiskLevels)
+	assert.Equal(t, map[string]int{"Violent Crime": 1}, s.Categories)
+}
+
+func TestScanCSV(t *testing.T) {
+	server := newScanServer(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "chats.csv")
+	writeFile(t, input, "id,prompt,response\nq1,hello,hi\nq2,bad,\n")
+
+	code, stdout, stderr := scan(t, server, "", "-input", input, "-quiet")
+	assert.Equal(t, exitOK, code, stderr)
+	records := readOutput(t, stdout)
+	require.Len(t, records, 2)
+	assert.Equal(t, "q1", records[0].ID)
+	assert.Equal(t, xiangxinai.HighRisk, records[1].RiskLevel)
+
+	// The response is sent as the assistant message
+	var messages []int
+	for _, request := range server.Requests() {
+		messages = append(messages, len(request.Messages))
+	}
+	assert.ElementsMatch(t, []int{2, 1}, messages)
+}
+
+func TestScanFailOn(t *testing.T) {
+	server := newScanServer(t)
+	input := "hello\nbad\n"
+
+	code, _, stderr := scan(t, server, input, "-format", "text", "-quiet", "-fail-on", "high_risk")
+	assert.Equal(t, exitFailOn, code)
+	assert.Contains(t, stderr, "1 records reached high_risk")
+
+	code, _, _ = scan(t, server, "hello\n", "-format", "text", "-quiet", "-fail-on", "high_risk")
+	assert.Equal(t, exitOK, code)
+
+	code, _, _ = scan(t, server, "hello\n", "-format", "text", "-quiet", "-fail-on", "no_risk")
+	assert.Equal(t, exitFailOn, code)
+
+	// The -fail-on level takes precedence over failed records
+	server.Fail(xiangxintest.Fault{Status: http.StatusBadRequest, Times: 1})
+	code, _, _ = scan(t, server, "bad\nbad\n", "-format", "text", "-quiet", "-fail-on", "high_risk")
+	assert.Equal(t, exitFailOn, code)
+	server.Fail(xiangxintest.Fault{Status: http.StatusBadRequest, Times: 1})
+	code, _, _ = scan(t, server, "hello\nhello\n", "-format", "text", "-quiet", "-fail-on", "high_risk")
+	assert.Equal(t, exitItemErrors, code)
+
+	code, _, stderr = scan(t, server, input, "-format", "text", "-quiet", "-fail-on", "severe")
+	assert.Equal(t, exitFatal, code)
+	assert.Contains(t, stderr, "invalid -fail-on")
+}
+
+func TestScanCheckpointAndResume(t *testing.T) {
+	server := newScanServer(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "prompts.txt")
+	output := filepath.Join(dir, "results.jsonl")
+	writeFile(t, input, "one\ntwo\nbad three\n")
+
+	code, stdout, stderr := scan(t, server, "", "-input", input, "-output", output, "-checkpoint-every", "2", "-quiet")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Empty(t, stdout)
+
+	cp, err := loadCheckpoint(output + ".checkpoint")
+	require.NoError(t, err)
+	assert.Equal(t, 3, cp.Processed)
+	assert.Equal(t, int64(len(readFile(t, output))), cp.OutputOffset)
+	assert.Equal(t, 3, cp.Summary.Total)
+
+	// Simulate an interruption after the checkpoint: more input arrives and
+	// the output ends with a partial line
+	writeFile(t, input, "one\ntwo\nbad three\nfour\nbad five\n")
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"index":3,"id":"4","risk_le`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	server.Reset()
+	server.OnContent("bad", xiangxintest.Verdict{Categories: []string{"Violent Crime"}})
+	code, _, stderr = scan(t, server, "", "-input", input, "-output", output, "-resume", "-quiet", "-fail-on", "high_risk")
+	assert.Equal(t, exitFailOn, code)
+	assert.Contains(t, stderr, "2 records reached high_risk")
+
+	// Only the new records are checked, and the partial line is replaced
+	assert.Equal(t, 2, server.RequestCount())
+	records := readOutput(t, readFile(t, output))
+	require.Len(t, records, 5)
+	for i, rec := range records {
+		assert.Equal(t, i, rec.Index)
+		assert.Empty(t, rec.Error)
+	}
+	assert.Equal(t, "4", records[3].ID)
+	assert.Equal(t, xiangxinai.HighRisk, records[4].RiskLevel)
+
+	cp, err = loadCheckpoint(output + ".checkpoint")
+	require.NoError(t, err)
+	assert.Equal(t, 5, cp.Processed)
+	assert.Equal(t, 5, cp.Summary.Total)
+	assert.Equal(t, 2, cp.Summary.RiskLevels["high_risk"])
+}
+
+func TestScanResumeErrors(t *testing.T) {
+	server := newScanServer(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "prompts.txt")
+	output := filepath.Join(dir, "results.jsonl")
+	writeFile(t, input, "one\n")
+
+	code, _, stderr := scan(t, server, "one\n", "-format", "text", "-resume")
+	assert.Equal(t, exitFatal, code)
+	assert.Contains(t, stderr, "-resume requires")
+
+	// A missing checkpoint starts from the beginning
+	code, _, stderr = scan(t, server, "", "-input", input, "-output", output, "-resume")
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stderr, "starting from the beginning")
+
+	// A checkpoint of another scan is rejected
+	other := filepath.Join(dir, "other.txt")
+	writeFile(t, other, "one\n")
+	code, _, stderr = scan(t, server, "", "-input", other, "-output", output, "-resume")
+	assert.Equal(t, exitFatal, code)
+	assert.Contains(t, stderr, "is for -input")
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+// outputRecord Result line written to the output
+type outputRecord struct {
+	Index      int                         `json:"index"`
+	ID         string                      `json:"id"`
+	RiskLevel  xiangxinai.RiskLevel        `json:"risk_level,omitempty"`
+	Action     xiangxinai.Action           `json:"action,omitempty"`
+	Categories []string                    `json:"categories,omitempty"`
+	Result     *xiangxinai.GuardrailResult `json:"result,omitempty"`
+	Answer     *string                     `json:"suggest_answer,omitempty"`
+	RequestID  string                      `json:"request_id,omitempty"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+// newOutputRecord Build the output line of a result
+func newOutputRecord(index int, id string, resp *xiangxinai.GuardrailResponse, err error) *outputRecord {
+	rec := &outputRecord{Index: index, ID: id}
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	rec.RiskLevel = resp.MaxRiskLevel()
+	rec.Action = resp.FinalAction()
+	rec.Categories = resp.GetAllCategories()
+	rec.Result = resp.Result
+	rec.Answer = resp.SuggestAnswer
+	rec.RequestID = resp.ID
+	return rec
+}
+
+// summary Counts of the scanned records
+type summary struct {
+	Total      int            `json:"total"`
+	Errors     int            `json:"errors"`
+	RiskLevels map[string]int `json:"risk_levels"`
+	Actions    map[string]int `json:"actions"`
+	Categories map[string]int `json:"categories"`
+}
+
+// newSummary Create empty summary
+func newSummary() *summary {
+	return &summary{
+		RiskLevels: make(map[string]int),
+		Actions:    make(map[string]int),
+		Categories: make(map[string]int),
+	}
+}
+
+// add Count an output record
+func (s *summary) add(rec *outputRecord) {
+	s.Total++
+	if rec.Error != "" {
+		s.Errors++
+		return
+	}
+	s.RiskLevels[string(rec.RiskLevel)]++
+	s.Actions[string(rec.Action)]++
+	for _, category := range rec.Categories {
+		s.Categories[category]++
+	}
+}
+
+// countAtLeast Return the number of records with a risk level of at least level
+func (s *summary) countAtLeast(level xiangxinai.RiskLevel) int {
+	count := 0
+	for name, n := range s.RiskLevels {
+		if xiangxinai.RiskLevel(name).AtLeast(level) {
+			count += n
+		}
+	}
+	return count
+}
+
+// print Write the human-readable summary
+func (s *summary) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Records:\t%d\n", s.Total)
+	fmt.Fprintf(tw, "Errors:\t%d\n", s.Errors)
+	printCounts(tw, "Risk levels", s.RiskLevels)
+	printCounts(tw, "Actions", s.Actions)
+	printCounts(tw, "Categories", s.Categories)
+	tw.Flush()
+}
+
+// printCounts Write counts sorted by descending count
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%d\n", name, counts[name])
+	}
+}
+
+// checkpoint Progress of a scan, written periodically to resume it
+type checkpoint struct {
+	Input        string   `json:"input"`
+	Output       string   `json:"output"`
+	Processed    int      `json:"processed"`     // Records written to the output
+	OutputOffset int64    `json:"output_offset"` // Output size after the processed records
+	Summary      *summary `json:"summary"`
+}
+
+// loadCheckpoint Read a checkpoint file
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	if cp.Summary == nil {
+		cp.Summary = newSummary()
+	}
+	return &cp, nil
+}
+
+// save Write the checkpoint atomically
+func (cp *checkpoint) save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+func TestSummary(t *testing.T) {
+	s := newSummary()
+	s.add(&outputRecord{RiskLevel: xiangxinai.NoRisk, Action: xiangxinai.ActionPass})
+	s.add(&outputRecord{RiskLevel: xiangxinai.HighRisk, Action: xiangxinai.ActionReject, Categories: []string{"Violent Crime"}})
+	s.add(&outputRecord{RiskLevel: xiangxinai.MediumRisk, Action: xiangxinai.ActionReplace, Categories: []string{"Violent Crime", "Insults"}})
+	s.add(&outputRecord{Error: "request failed"})
+
+	assert.Equal(t, 4, s.Total)
+	assert.Equal(t, 1, s.Errors)
+	assert.Equal(t, 2, s.Categories["Violent Crime"])
+	assert.Equal(t, 1, s.countAtLeast(xiangxinai.HighRisk))
+	assert.Equal(t, 2, s.countAtLeast(xiangxinai.MediumRisk))
+	assert.Equal(t, 3, s.countAtLeast(xiangxinai.NoRisk))
+
+	var out strings.Builder
+	s.print(&out)
+	assert.Equal(t, strings.Join([]string{
+		"Records:  4",
+		"Errors:   1",
+		"Risk levels:",
+		"  high_risk    1",
+		"  medium_risk  1",
+		"  no_risk      1",
+		"Actions:",
+		"  pass     1",
+		"  reject   1",
+		"  replace  1",
+		"Categories:",
+		"  Violent Crime  2",
+		"  Insults        1",
+		"",
+	}, "\n"), out.String())
+}
+
+func TestNewOutputRecord(t *testing.T) {
+	rec := newOutputRecord(3, "id-3", nil, errors.New("boom"))
+	assert.Equal(t, &outputRecord{Index: 3, ID: "id-3", Error: "boom"}, rec)
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl.checkpoint")
+	_, err := loadCheckpoint(path)
+	require.Error(t, err)
+
+	cp := &checkpoint{Input: "in.txt", Output: "out.jsonl", Processed: 2, OutputOffset: 120, Summary: newSummary()}
+	cp.Summary.add(&outputRecord{RiskLevel: xiangxinai.NoRisk, Action: xiangxinai.ActionPass})
+	require.NoError(t, cp.save(path))
+	assert.NoFileExists(t, path+".tmp")
+
+	loaded, err := loadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, cp, loaded)
+}
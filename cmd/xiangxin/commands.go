@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+// checkPrompt Run "check prompt"
+func checkPrompt(args []string) error {
+	fs, f := newFlagSet("check prompt", "<text | ->")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	prompt, err := readArg(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+
+	client, err := f.newClient()
+	if err != nil {
+		return err
+	}
+	return runWithTiming(f, func(ctx context.Context) error {
+		var result *xiangxinai.GuardrailResponse
+		var err error
+		if f.model != "" {
+			// /guardrails/input does not take a model
+			messages := []*xiangxinai.Message{xiangxinai.NewMessage("user", prompt)}
+			result, err = client.CheckConversationWithModel(ctx, messages, f.model, f.userIDs()...)
+		} else {
+			result, err = client.CheckPrompt(ctx, prompt, f.userIDs()...)
+		}
+		if err != nil {
+			return err
+		}
+		return printResponse(f, result)
+	})
+}
+
+// checkResponse Run "check response"
+func checkResponse(args []string) error {
+	fs, f := newFlagSet("check response", "-prompt <text> <response | ->")
+	prompt := fs.String("prompt", "", "Prompt the response answers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	response, err := readArg(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+
+	client, err := f.newClient()
+	if err != nil {
+		return err
+	}
+	return runWithTiming(f, func(ctx context.Context) error {
+		result, err := client.CheckResponseCtx(ctx, *prompt, response, f.userIDs()...)
+		if err != nil {
+			return err
+		}
+		return printResponse(f, result)
+	})
+}
+
+// checkConversation Run "check conversation"
+func checkConversation(args []string) error {
+	fs, f := newFlagSet("check conversation", "-file <messages.json | ->")
+	file := fs.String("file", "", `JSON file with an OpenAI messages array or an object with a "messages" field, - for stdin`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return errUsage
+	}
+	messages, err := readMessages(*file)
+	if err != nil {
+		return err
+	}
+
+	client, err := f.newClient()
+	if err != nil {
+		return err
+	}
+	model := firstNonEmpty(f.model, xiangxinai.DefaultModel)
+	return runWithTiming(f, func(ctx context.Context) error {
+		result, err := client.CheckConversationWithModel(ctx, messages, model, f.userIDs()...)
+		if err != nil {
+			return err
+		}
+		return printResponse(f, result)
+	})
+}
+
+// checkImage Run "check image"
+func checkImage(args []string) error {
+	fs, f := newFlagSet("check image", "[-prompt <text>] <path or URL>...")
+	prompt := fs.String("prompt", "", "Text prompt sent with the images")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := f.newClient()
+	if err != nil {
+		return err
+	}
+	return runWithTiming(f, func(ctx context.Context) error {
+		var result *xiangxinai.GuardrailResponse
+		var err error
+		if f.model != "" {
+			result, err = client.CheckPromptImagesWithModel(ctx, *prompt, fs.Args(), f.model, f.userIDs()...)
+		} else {
+			result, err = client.CheckPromptImages(ctx, *prompt, fs.Args(), f.userIDs()...)
+		}
+		if err != nil {
+			return err
+		}
+		return printResponse(f, result)
+	})
+}
+
+// health Run "health"
+func health(args []string) error {
+	fs, f := newFlagSet("health", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := f.newClient()
+	if err != nil {
+		return err
+	}
+	return runWithTiming(f, func(ctx context.Context) error {
+		status, err := client.Health(ctx)
+		if err != nil {
+			return err
+		}
+		if f.json {
+			return printJSON(status.Raw)
+		}
+		printHealth(status)
+		return nil
+	})
+}
+
+// models Run "models"
+func models(args []string) error {
+	fs, f := newFlagSet("models", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := f.newClient()
+	if err != nil {
+		return err
+	}
+	return runWithTiming(f, func(ctx context.Context) error {
+		list, err := client.ListModels(ctx)
+		if err != nil {
+			return err
+		}
+		if f.json {
+			return printJSON(list)
+		}
+		printModels(list)
+		return nil
+	})
+}
+
+// readMessages Read an OpenAI messages array, optionally wrapped in {"messages": [...]}
+func readMessages(path string) ([]*xiangxinai.Message, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		var text string
+		text, err = readArg(path)
+		data = []byte(text)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []*xiangxinai.Message
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var wrapper struct {
+			Messages []*xiangxinai.Message `json:"messages"`
+		}
+		err = json.Unmarshal(data, &wrapper)
+		messages = wrapper.Messages
+	} else {
+		err = json.Unmarshal(data, &messages)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid messages file %s: %w", path, err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,242 @@
+// Command xiangxin runs ad-hoc checks and diagnostics against the Xiangxin AI
+// Guardrails API, e.g. to reproduce a disputed moderation decision.
+//
+// Usage:
+//
+//	xiangxin check prompt [flags] <text | ->
+//	xiangxin check response [flags] -prompt <text> <response | ->
+//	xiangxin check conversation [flags] -file <messages.json | ->
+//	xiangxin check image [flags] [-prompt <text>] <path or URL>...
+//	xiangxin health [flags]
+//	xiangxin models [flags]
+//
+// Flags go before the arguments. The API key is read from -api-key, then
+// $XIANGXINAI_API_KEY, then the api_key field of the config file
+// (-config, $XIANGXIN_CONFIG, or config.yaml in the user config directory's
+// xiangxin folder, e.g. ~/.config/xiangxin/config.yaml):
+//
+//	api_key: your-api-key
+//	base_url: https://api.xiangxinai.cn/v1
+//	model: Xiangxin-Guardrails-Text
+//
+// Results are printed as text, or as the full JSON response with -json.
+// -verbose prints each HTTP attempt with its request body, status and
+// latency, and the retries, to stderr.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+const usage = `Usage: xiangxin <command> [flags] [arguments]
+
+Commands:
+  check prompt [flags] <text | ->                    Check a user prompt
+  check response [flags] -prompt <text> <text | ->   Check a model response to a prompt
+  check conversation [flags] -file <file | ->        Check an OpenAI messages array
+  check image [flags] [-prompt <text>] <image>...    Check images (paths or URLs) with an optional prompt
+  health [flags]                                     Show the API health status
+  models [flags]                                     List the available models
+
+Run "xiangxin <command> -h" for the flags of a command.
+`
+
+// errUsage Invalid command line, the usage has been printed
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "xiangxin: %v\n", err)
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+}
+
+// run Dispatch the command
+func run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return errUsage
+	}
+	switch args[0] {
+	case "check":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return errUsage
+		}
+		switch args[1] {
+		case "prompt":
+			return checkPrompt(args[2:])
+		case "response":
+			return checkResponse(args[2:])
+		case "conversation":
+			return checkConversation(args[2:])
+		case "image":
+			return checkImage(args[2:])
+		}
+	case "health":
+		return health(args[1:])
+	case "models":
+		return models(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", args[0], usage)
+	return errUsage
+}
+
+// config Config file contents
+type config struct {
+	APIKey  string `yaml:"api_key"`
+	BaseURL string `yaml:"base_url"`
+	Model   string `yaml:"model"`
+}
+
+// commonFlags Flags shared by all commands
+type commonFlags struct {
+	apiKey     string
+	baseURL    string
+	configFile string
+	model      string
+	userID     string
+	timeout    time.Duration
+	retries    int
+	json       bool
+	verbose    bool
+}
+
+// newFlagSet Create the flag set of a command with the common flags
+func newFlagSet(name, args string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: xiangxin %s\n\nFlags:\n", strings.TrimSpace(name+" [flags] "+args))
+		fs.PrintDefaults()
+	}
+	f := &commonFlags{}
+	fs.StringVar(&f.apiKey, "api-key", "", "API key (default $XIANGXINAI_API_KEY or the config file)")
+	fs.StringVar(&f.baseURL, "base-url", "", "API base URL (default $XIANGXINAI_BASE_URL, the config file or "+xiangxinai.DefaultBaseURL+")")
+	fs.StringVar(&f.configFile, "config", "", "Config file (default $XIANGXIN_CONFIG or <user config dir>/xiangxin/config.yaml)")
+	fs.StringVar(&f.model, "model", "", "Guardrail model (default the config file or the command's default model)")
+	fs.StringVar(&f.userID, "user-id", "", "Tenant AI application user ID")
+	fs.DurationVar(&f.timeout, "timeout", 30*time.Second, "Request timeout")
+	fs.IntVar(&f.retries, "retries", xiangxinai.DefaultMaxRetries, "Maximum retry count")
+	fs.BoolVar(&f.json, "json", false, "Print the full JSON response")
+	fs.BoolVar(&f.verbose, "verbose", false, "Print HTTP requests, latency and retries to stderr")
+	return fs, f
+}
+
+// newClient Create the client from the flags, environment and config file
+func (f *commonFlags) newClient() (*xiangxinai.Client, error) {
+	cfg, err := loadConfig(f.configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := firstNonEmpty(f.apiKey, os.Getenv("XIANGXINAI_API_KEY"), cfg.APIKey)
+	if apiKey == "" {
+		return nil, errors.New("no API key: set -api-key, $XIANGXINAI_API_KEY or api_key in the config file")
+	}
+	if f.model == "" {
+		f.model = cfg.Model
+	}
+
+	var retryPolicy xiangxinai.RetryPolicy = xiangxinai.NewDefaultRetryPolicy(f.retries)
+	opts := []xiangxinai.Option{
+		xiangxinai.WithBaseURL(firstNonEmpty(f.baseURL, os.Getenv("XIANGXINAI_BASE_URL"), cfg.BaseURL, xiangxinai.DefaultBaseURL)),
+		xiangxinai.WithTimeout(f.timeout),
+		xiangxinai.WithUserAgentSuffix("xiangxin-cli"),
+	}
+	if f.verbose {
+		retryPolicy = &verboseRetryPolicy{policy: retryPolicy, w: os.Stderr}
+		opts = append(opts, xiangxinai.WithTransport(&verboseTransport{w: os.Stderr}))
+	}
+	opts = append(opts, xiangxinai.WithRetryPolicy(retryPolicy))
+
+	return xiangxinai.NewClient(apiKey, opts...)
+}
+
+// userIDs Return the optional user ID argument of the check methods
+func (f *commonFlags) userIDs() []string {
+	if f.userID == "" {
+		return nil
+	}
+	return []string{f.userID}
+}
+
+// loadConfig Read the config file, a missing default config file is empty
+func loadConfig(path string) (*config, error) {
+	explicit := path != ""
+	if path == "" {
+		path = os.Getenv("XIANGXIN_CONFIG")
+		explicit = path != ""
+	}
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return &config{}, nil
+		}
+		path = filepath.Join(dir, "xiangxin", "config.yaml")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// readArg Return the argument, or stdin if it is "-"
+func readArg(arg string) (string, error) {
+	if arg != "-" {
+		return arg, nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return string(data), nil
+}
+
+// runWithTiming Run fn, printing its latency in verbose mode
+func runWithTiming(f *commonFlags, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := fn(context.Background())
+	if f.verbose {
+		fmt.Fprintf(os.Stderr, "* completed in %s\n", time.Since(start).Round(time.Millisecond))
+	}
+	return err
+}
+
+// firstNonEmpty Return the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+// printResponse Print a detection result
+func printResponse(f *commonFlags, resp *xiangxinai.GuardrailResponse) error {
+	if f.json {
+		return printJSON(resp)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Request ID:\t%s\n", resp.ID)
+	fmt.Fprintf(tw, "Overall risk:\t%s\n", resp.OverallRiskLevel)
+	for _, dimension := range xiangxinai.Dimensions {
+		level := resp.RiskFor(dimension)
+		if level == "" {
+			continue
+		}
+		line := string(level)
+		if categories := resp.CategoriesFor(dimension); len(categories) > 0 {
+			line += " (" + strings.Join(categories, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "  %s:\t%s\n", dimension, line)
+	}
+	fmt.Fprintf(tw, "Suggested action:\t%s\n", resp.SuggestAction)
+	if resp.SuggestAnswer != nil {
+		fmt.Fprintf(tw, "Suggested answer:\t%s\n", *resp.SuggestAnswer)
+	}
+	if resp.Score != nil {
+		fmt.Fprintf(tw, "Score:\t%.4f\n", *resp.Score)
+	}
+	if resp.Cached {
+		fmt.Fprintf(tw, "Cached:\ttrue\n")
+	}
+	if resp.Degraded {
+		fmt.Fprintf(tw, "Degraded:\t%s\n", resp.DegradedReason)
+	}
+	return tw.Flush()
+}
+
+// printJSON Print a value as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printHealth Print the health status
+func printHealth(status *xiangxinai.HealthStatus) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Status:\t%s\n", status.Status)
+	if status.Version != "" {
+		fmt.Fprintf(tw, "Version:\t%s\n", status.Version)
+	}
+	fmt.Fprintf(tw, "Latency:\t%s\n", status.Latency.Round(time.Millisecond))
+
+	names := make([]string, 0, len(status.Components))
+	for name := range status.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		component := status.Components[name]
+		line := component.Status
+		if component.Message != "" {
+			line += ": " + component.Message
+		}
+		fmt.Fprintf(tw, "  %s:\t%s\n", name, line)
+	}
+	tw.Flush()
+}
+
+// printModels Print the model list as a table
+func printModels(models xiangxinai.ModelList) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tIMAGES\tMAX CONTEXT\tDIMENSIONS")
+	for _, model := range models {
+		maxContext := "-"
+		if model.MaxContextLength > 0 {
+			maxContext = strconv.Itoa(model.MaxContextLength)
+		}
+		dimensions := "-"
+		if len(model.Dimensions) > 0 {
+			names := make([]string, len(model.Dimensions))
+			for i, dimension := range model.Dimensions {
+				names[i] = string(dimension)
+			}
+			dimensions = strings.Join(names, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%t\t%s\t%s\n", model.ID, model.SupportsImages(), maxContext, dimensions)
+	}
+	tw.Flush()
+}
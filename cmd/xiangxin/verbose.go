@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+// maxVerboseString Longest JSON string printed in full, e.g. base64 images are elided
+const maxVerboseString = 256
+
+// verboseTransport Transport printing each HTTP attempt
+type verboseTransport struct {
+	w       io.Writer
+	attempt int
+}
+
+// RoundTrip Print the request and response of an attempt
+func (t *verboseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.attempt++
+	fmt.Fprintf(t.w, "> %s %s (attempt %d)\n", req.Method, req.URL, t.attempt)
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			fmt.Fprintf(t.w, "%s\n", formatBody(data))
+		}
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(t.w, "< error after %s: %v\n", latency, err)
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	fmt.Fprintf(t.w, "< %s (%s)\n", resp.Status, latency)
+	if err == nil && len(data) > 0 {
+		fmt.Fprintf(t.w, "%s\n", formatBody(data))
+	}
+	return resp, err
+}
+
+// verboseRetryPolicy Retry policy printing retry decisions
+type verboseRetryPolicy struct {
+	policy xiangxinai.RetryPolicy
+	w      io.Writer
+}
+
+// ShouldRetry Decide whether the failed attempt should be retried
+func (p *verboseRetryPolicy) ShouldRetry(attempt xiangxinai.RetryAttempt) bool {
+	retry := p.policy.ShouldRetry(attempt)
+	if !retry {
+		fmt.Fprintf(p.w, "* attempt %d failed, not retrying: %v\n", attempt.Attempt+1, attempt.Err)
+	}
+	return retry
+}
+
+// NextDelay Return the delay before the next attempt
+func (p *verboseRetryPolicy) NextDelay(attempt xiangxinai.RetryAttempt) time.Duration {
+	delay := p.policy.NextDelay(attempt)
+	fmt.Fprintf(p.w, "* attempt %d failed, retrying in %s: %v\n", attempt.Attempt+1, delay.Round(time.Millisecond), attempt.Err)
+	return delay
+}
+
+// formatBody Indent a JSON body, eliding long strings
+func formatBody(data []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(elide(v))
+	return string(bytes.TrimRight(buf.Bytes(), "\n"))
+}
+
+// elide Shorten long strings in a decoded JSON value
+func elide(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if len(v) > maxVerboseString {
+			// Cut at a character boundary so multi-byte text stays valid UTF-8
+			end := maxVerboseString
+			for end > 0 && !utf8.RuneStart(v[end]) {
+				end--
+			}
+			return fmt.Sprintf("%s... (%d bytes)", v[:end], len(v))
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = elide(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = elide(v[key])
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElideKeepsValidUTF8(t *testing.T) {
+	long := strings.Repeat("a", maxVerboseString-1) + strings.Repeat("你", 10)
+	elided := elide(long).(string)
+	assert.True(t, utf8.ValidString(elided))
+	assert.True(t, strings.HasPrefix(elided, strings.Repeat("a", maxVerboseString-1)+"..."))
+	assert.Equal(t, "short", elide("short"))
+}
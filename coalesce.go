@@ -0,0 +1,225 @@
+package xiangxinai
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CoalesceStats Request coalescing statistics, returned by Client.CoalesceStats
+type CoalesceStats struct {
+	Calls     int64 // Check calls that went through coalescing
+	Requests  int64 // Requests sent to the API
+	Coalesced int64 // Calls served by another call's in-flight request
+}
+
+// DedupRatio Return the fraction of calls served by another call's request
+func (s CoalesceStats) DedupRatio() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Coalesced) / float64(s.Calls)
+}
+
+// coalescer Deduplicates concurrent identical requests
+//
+// The first caller of a key starts the request with a context detached from
+// its own cancellation; later callers of the same key wait for its result.
+// The request runs under the earliest deadline of the waiting callers, so
+// deadline-aware steps such as the rate limiter still fail fast. Each caller
+// stops waiting when its own context is done, and the shared request is
+// cancelled once no caller is waiting any more. Every waiter that receives the
+// result is credited with the attempts of the shared request.
+type coalescer struct {
+	// Counters first, for 64-bit alignment of atomic operations on 32-bit platforms
+	numCalls     int64
+	numRequests  int64
+	numCoalesced int64
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall In-flight shared request
+type coalescedCall struct {
+	done     chan struct{}
+	body     []byte
+	err      error
+	attempts int // HTTP attempts of the shared request
+	waiters  int
+	ctx      *sharedContext
+}
+
+// newCoalescer Create coalescer
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*coalescedCall)}
+}
+
+// do Run fn once for all concurrent callers of key, returning the shared response body
+func (g *coalescer) do(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	atomic.AddInt64(&g.numCalls, 1)
+
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if ok {
+		call.waiters++
+		call.ctx.join(ctx)
+		g.mu.Unlock()
+		atomic.AddInt64(&g.numCoalesced, 1)
+	} else {
+		call = &coalescedCall{done: make(chan struct{}), waiters: 1, ctx: newSharedContext(ctx)}
+		g.calls[key] = call
+		g.mu.Unlock()
+		atomic.AddInt64(&g.numRequests, 1)
+
+		go func() {
+			defer call.ctx.stop()
+			callCtx, stats := withCallStats(call.ctx)
+			call.body, call.err = fn(callCtx)
+			call.attempts = stats.attemptCount()
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+
+	select {
+	case <-call.done:
+		addAttempts(ctx, call.attempts)
+		return call.body, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Nobody is waiting: cancel the request and let new callers start a fresh one
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			call.ctx.stop()
+		}
+		g.mu.Unlock()
+		return nil, NewNetworkError("request failed", ctx.Err())
+	}
+}
+
+// stats Return the coalescing statistics
+func (g *coalescer) stats() CoalesceStats {
+	return CoalesceStats{
+		Calls:     atomic.LoadInt64(&g.numCalls),
+		Requests:  atomic.LoadInt64(&g.numRequests),
+		Coalesced: atomic.LoadInt64(&g.numCoalesced),
+	}
+}
+
+// detachedContext Context keeping the values of its parent but not its deadline or cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline Return no deadline
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done Return nil, the context is never cancelled
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err Return nil, the context is never cancelled
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Value Return the parent's value
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// sharedContext Context of a coalesced request
+//
+// It keeps the values of the first caller and the earliest deadline of the
+// callers that joined, and is cancelled by stop or when the deadline passes.
+type sharedContext struct {
+	context.Context // Values of the first caller, cancelled by cancel
+	cancel          context.CancelFunc
+
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	expired  bool
+}
+
+// newSharedContext Create the context of a request started by the caller with ctx
+func newSharedContext(ctx context.Context) *sharedContext {
+	inner, cancel := context.WithCancel(detachedContext{ctx})
+	s := &sharedContext{Context: inner, cancel: cancel}
+	s.join(ctx)
+	return s
+}
+
+// join Adopt the deadline of a caller waiting for the request if it is earlier
+func (s *sharedContext) join(ctx context.Context) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.deadline.IsZero() && !deadline.Before(s.deadline) {
+		return
+	}
+	s.deadline = deadline
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(time.Until(deadline), func() {
+		s.mu.Lock()
+		s.expired = true
+		s.mu.Unlock()
+		s.cancel()
+	})
+}
+
+// stop Cancel the request and release the deadline timer
+func (s *sharedContext) stop() {
+	s.cancel()
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.mu.Unlock()
+}
+
+// Deadline Return the earliest deadline of the callers
+func (s *sharedContext) Deadline() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deadline, !s.deadline.IsZero()
+}
+
+// Err Return context.DeadlineExceeded once the deadline passed, otherwise the cancellation error
+func (s *sharedContext) Err() error {
+	err := s.Context.Err()
+	if err == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expired {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// CoalesceStats Return the request coalescing statistics, zero if coalescing is disabled
+func (c *Client) CoalesceStats() CoalesceStats {
+	if c.coalescer == nil {
+		return CoalesceStats{}
+	}
+	return c.coalescer.stats()
+}
@@ -0,0 +1,130 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// recordingLogger Logger recording the key-value arguments of each message
+type recordingLogger struct {
+	mu      sync.Mutex
+	records []logRecord
+}
+
+// logRecord Recorded log message
+type logRecord struct {
+	level string
+	msg   string
+	args  map[string]interface{}
+}
+
+func (l *recordingLogger) record(level, msg string, args []interface{}) {
+	record := logRecord{level: level, msg: msg, args: make(map[string]interface{})}
+	for i := 0; i+1 < len(args); i += 2 {
+		record.args[args[i].(string)] = args[i+1]
+	}
+	l.mu.Lock()
+	l.records = append(l.records, record)
+	l.mu.Unlock()
+}
+
+func (l *recordingLogger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	l.record("debug", msg, args)
+}
+
+func (l *recordingLogger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.record("warn", msg, args)
+}
+
+func (l *recordingLogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.record("error", msg, args)
+}
+
+// messages Return the records with the given message
+func (l *recordingLogger) messages(msg string) []logRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var records []logRecord
+	for _, record := range l.records {
+		if record.msg == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestCoalescingSharesRequest(t *testing.T) {
+	logger := &recordingLogger{}
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithRequestCoalescing(), xiangxinai.WithLogger(logger))
+	server.SetLatency(200 * time.Millisecond)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = client.CheckPrompt(context.Background(), "same content")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, server.RequestCount())
+	stats := client.CoalesceStats()
+	assert.Equal(t, int64(callers), stats.Calls)
+	assert.Equal(t, int64(1), stats.Requests)
+	assert.Equal(t, int64(callers-1), stats.Coalesced)
+
+	// Every caller is credited with the shared attempt
+	completed := logger.messages("guardrail request completed")
+	require.Len(t, completed, callers)
+	for _, record := range completed {
+		assert.Equal(t, 1, record.args["attempts"])
+	}
+}
+
+func TestCoalescingKeepsCallerDeadline(t *testing.T) {
+	client, _ := xiangxintest.NewClient(t,
+		xiangxinai.WithRequestCoalescing(),
+		xiangxinai.WithRateLimit(xiangxinai.RateLimitConfig{QPS: 0.5, Burst: 1}),
+	)
+	_, err := client.CheckPrompt(context.Background(), "first") // Takes the only token
+	require.NoError(t, err)
+
+	// The next token is two seconds away, beyond the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.CheckPrompt(ctx, "second")
+	assert.True(t, errors.Is(err, xiangxinai.ErrRateLimited), "got %v", err)
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestCoalescingCancelsAbandonedRequest(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithRequestCoalescing())
+	server.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.CheckPrompt(ctx, "slow")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+
+	// A new call starts a fresh request instead of joining the abandoned one
+	server.SetLatency(0)
+	_, err = client.CheckPrompt(context.Background(), "slow")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), client.CoalesceStats().Requests)
+}
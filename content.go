@@ -0,0 +1,279 @@
+package xiangxinai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Content part types
+const (
+	// ContentPartText Text content part type
+	ContentPartText = "text"
+	// ContentPartImageURL Image URL content part type
+	ContentPartImageURL = "image_url"
+)
+
+// ContentPart Multimodal message content part
+//
+// Implemented by TextPart, ImageURLPart and RawPart. Each part marshals to the
+// OpenAI style object form, e.g. {"type": "text", "text": "..."}. Parts of other
+// types are sent as they marshal, so custom parts can implement ContentPart
+// together with json.Marshaler.
+type ContentPart interface {
+	// PartType Return the content part type, e.g. "text" or "image_url"
+	PartType() string
+}
+
+// TextPart Text content part
+type TextPart struct {
+	Text string // Text content
+}
+
+// NewTextPart Create new text content part
+func NewTextPart(text string) *TextPart {
+	return &TextPart{Text: text}
+}
+
+// PartType Return the content part type
+func (p *TextPart) PartType() string {
+	return ContentPartText
+}
+
+// MarshalJSON Marshal text part to {"type": "text", "text": "..."}
+func (p *TextPart) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}{
+		Type: ContentPartText,
+		Text: p.Text,
+	})
+}
+
+// ImageURL Image URL reference, either a http(s) link or a base64 data URL
+type ImageURL struct {
+	URL    string `json:"url"`              // Image URL or data URL
+	Detail string `json:"detail,omitempty"` // Optional detail level: auto, low, high
+}
+
+// ImageURLPart Image content part
+type ImageURLPart struct {
+	ImageURL ImageURL // Image reference
+}
+
+// NewImageURLPart Create new image content part
+func NewImageURLPart(url string) *ImageURLPart {
+	return &ImageURLPart{ImageURL: ImageURL{URL: url}}
+}
+
+// PartType Return the content part type
+func (p *ImageURLPart) PartType() string {
+	return ContentPartImageURL
+}
+
+// MarshalJSON Marshal image part to {"type": "image_url", "image_url": {"url": "..."}}
+func (p *ImageURLPart) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string   `json:"type"`
+		ImageURL ImageURL `json:"image_url"`
+	}{
+		Type:     ContentPartImageURL,
+		ImageURL: p.ImageURL,
+	})
+}
+
+// RawPart Content part of a type the SDK does not model, kept as raw JSON
+//
+// Decoding content with an unknown part type yields a RawPart, which marshals
+// back to the original object, so new server-side part types pass through.
+type RawPart struct {
+	Type string          // Content part type
+	JSON json.RawMessage // Complete part object, including the type field
+}
+
+// NewRawPart Create a content part from a JSON object with a type field
+func NewRawPart(data []byte) (*RawPart, error) {
+	var raw struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, NewValidationError(fmt.Sprintf("invalid content part: %v", err))
+	}
+	if raw.Type == "" {
+		return nil, NewValidationError("content part must have a type")
+	}
+	return &RawPart{Type: raw.Type, JSON: append(json.RawMessage(nil), data...)}, nil
+}
+
+// PartType Return the content part type
+func (p *RawPart) PartType() string {
+	return p.Type
+}
+
+// MarshalJSON Marshal the part to its raw JSON object
+func (p *RawPart) MarshalJSON() ([]byte, error) {
+	return p.JSON, nil
+}
+
+// MessageContent Message content, either plain text or a list of multimodal parts
+//
+// It marshals to a JSON string when Parts is nil, and to a JSON array otherwise,
+// so both forms accepted by the API round-trip unchanged.
+type MessageContent struct {
+	Text  string        // Plain text content, used when Parts is nil
+	Parts []ContentPart // Multimodal content parts
+}
+
+// NewTextContent Create plain text message content
+func NewTextContent(text string) MessageContent {
+	return MessageContent{Text: text}
+}
+
+// NewPartsContent Create multimodal message content
+func NewPartsContent(parts ...ContentPart) MessageContent {
+	if parts == nil {
+		parts = []ContentPart{}
+	}
+	return MessageContent{Parts: parts}
+}
+
+// IsMultimodal Check if the content is in array form
+func (c MessageContent) IsMultimodal() bool {
+	return c.Parts != nil
+}
+
+// String Return the text of the content, joining text parts with newlines
+func (c MessageContent) String() string {
+	if !c.IsMultimodal() {
+		return c.Text
+	}
+	var texts []string
+	for _, part := range c.Parts {
+		if p, ok := part.(*TextPart); ok && p != nil {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// MarshalJSON Marshal content to a JSON string or array
+func (c MessageContent) MarshalJSON() ([]byte, error) {
+	if !c.IsMultimodal() {
+		return json.Marshal(c.Text)
+	}
+	return json.Marshal(c.Parts)
+}
+
+// UnmarshalJSON Unmarshal content from a JSON string or array
+func (c *MessageContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		*c = MessageContent{}
+		return nil
+	}
+
+	if data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*c = MessageContent{Text: text}
+		return nil
+	}
+
+	var rawParts []json.RawMessage
+	if err := json.Unmarshal(data, &rawParts); err != nil {
+		return fmt.Errorf("message content must be a string or an array of content parts: %w", err)
+	}
+
+	parts := make([]ContentPart, 0, len(rawParts))
+	for _, raw := range rawParts {
+		part, err := unmarshalContentPart(raw)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+	}
+	*c = MessageContent{Parts: parts}
+	return nil
+}
+
+// unmarshalContentPart Decode a single content part by its type field
+func unmarshalContentPart(data []byte) (ContentPart, error) {
+	var raw struct {
+		Type     string    `json:"type"`
+		Text     string    `json:"text"`
+		ImageURL *ImageURL `json:"image_url"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid content part: %w", err)
+	}
+
+	switch raw.Type {
+	case ContentPartText:
+		return &TextPart{Text: raw.Text}, nil
+	case ContentPartImageURL:
+		if raw.ImageURL == nil {
+			return nil, fmt.Errorf("image_url content part missing image_url field")
+		}
+		return &ImageURLPart{ImageURL: *raw.ImageURL}, nil
+	default:
+		if raw.Type == "" {
+			return nil, fmt.Errorf("content part missing type field")
+		}
+		return &RawPart{Type: raw.Type, JSON: append(json.RawMessage(nil), data...)}, nil
+	}
+}
+
+// normalize Validate content and trim whitespace, returning false if nothing remains
+func (c MessageContent) normalize() (MessageContent, bool, error) {
+	if !c.IsMultimodal() {
+		if len(c.Text) > maxContentLength {
+			return MessageContent{}, false, NewValidationError(fmt.Sprintf("content too long (max %d characters)", maxContentLength))
+		}
+		text := strings.TrimSpace(c.Text)
+		return MessageContent{Text: text}, text != "", nil
+	}
+
+	total := 0
+	parts := make([]ContentPart, 0, len(c.Parts))
+	for _, part := range c.Parts {
+		switch p := part.(type) {
+		case *TextPart:
+			if p == nil {
+				continue
+			}
+			total += len(p.Text)
+			if total > maxContentLength {
+				return MessageContent{}, false, NewValidationError(fmt.Sprintf("content too long (max %d characters)", maxContentLength))
+			}
+			if text := strings.TrimSpace(p.Text); text != "" {
+				parts = append(parts, &TextPart{Text: text})
+			}
+		case *ImageURLPart:
+			if p == nil {
+				continue
+			}
+			if strings.TrimSpace(p.ImageURL.URL) == "" {
+				return MessageContent{}, false, NewValidationError("image_url content part must have a url")
+			}
+			parts = append(parts, p)
+		case *RawPart:
+			if p == nil {
+				continue
+			}
+			if len(p.JSON) == 0 {
+				return MessageContent{}, false, NewValidationError(fmt.Sprintf("%s content part has no JSON", p.Type))
+			}
+			parts = append(parts, p)
+		case nil:
+			continue
+		default:
+			// Custom part, sent as it marshals
+			parts = append(parts, part)
+		}
+	}
+	return MessageContent{Parts: parts}, len(parts) > 0, nil
+}
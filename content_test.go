@@ -0,0 +1,33 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestRawPartPassesThrough(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	audio, err := xiangxinai.NewRawPart([]byte(`{"type":"input_audio","input_audio":{"data":"UklGRg==","format":"wav"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "input_audio", audio.PartType())
+
+	messages := []*xiangxinai.Message{{
+		Role:    "user",
+		Content: xiangxinai.NewPartsContent(xiangxinai.NewTextPart("transcribe this"), audio),
+	}}
+	_, err = client.CheckConversation(context.Background(), messages)
+	require.NoError(t, err)
+
+	request, ok := server.LastRequest()
+	require.True(t, ok)
+	assert.Contains(t, string(request.Body), `"input_audio":{"data":"UklGRg==","format":"wav"}`)
+	require.Len(t, request.Messages, 1)
+	require.Len(t, request.Messages[0].Content.Parts, 2)
+	assert.Equal(t, "input_audio", request.Messages[0].Content.Parts[1].PartType())
+}
@@ -0,0 +1,20 @@
+package xiangxinai
+
+import "context"
+
+// resultContextKey Context key of the guardrail result
+type resultContextKey struct{}
+
+// ContextWithResult Return a copy of ctx carrying the guardrail result
+//
+// Used by the guard middleware packages so handlers can read the result of
+// the check with ResultFromContext.
+func ContextWithResult(ctx context.Context, result *GuardrailResponse) context.Context {
+	return context.WithValue(ctx, resultContextKey{}, result)
+}
+
+// ResultFromContext Return the guardrail result stored in ctx
+func ResultFromContext(ctx context.Context) (*GuardrailResponse, bool) {
+	result, ok := ctx.Value(resultContextKey{}).(*GuardrailResponse)
+	return result, ok && result != nil
+}
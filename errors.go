@@ -1,6 +1,13 @@
 package xiangxinai
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // XiangxinAIError Xiangxin AI Guardrails base error class
 type XiangxinAIError struct {
@@ -85,4 +92,119 @@ func NewServerError(message string) *ServerError {
 	return &ServerError{
 		XiangxinAIError: &XiangxinAIError{Message: message},
 	}
-}
\ No newline at end of file
+}
+
+// FieldError Field validation error, as returned in FastAPI style 422 responses
+type FieldError struct {
+	Loc  []interface{} `json:"loc"`  // Error location, e.g. ["body", "messages", 0, "content"]
+	Msg  string        `json:"msg"`  // Error message
+	Type string        `json:"type"` // Error type, e.g. "missing"
+}
+
+// Field Return the error location joined with dots, e.g. "body.messages.0.content"
+func (e FieldError) Field() string {
+	parts := make([]string, 0, len(e.Loc))
+	for _, loc := range e.Loc {
+		parts = append(parts, fmt.Sprint(loc))
+	}
+	return strings.Join(parts, ".")
+}
+
+// APIError API error response
+//
+// APIError carries the details of a non-2xx response. AuthenticationError,
+// ValidationError, RateLimitError and ServerError wrap it, so it can be
+// retrieved from any of them with errors.As:
+//
+//	var apiErr *xiangxinai.APIError
+//	if errors.As(err, &apiErr) {
+//		fmt.Println(apiErr.StatusCode, apiErr.RequestID, apiErr.Detail)
+//	}
+type APIError struct {
+	*XiangxinAIError
+	StatusCode  int           // HTTP status code
+	RequestID   string        // Server request ID, from the X-Request-ID header or the response id
+	RetryAfter  time.Duration // Value of the Retry-After header, zero if absent
+	Detail      string        // Parsed error detail
+	FieldErrors []FieldError  // Field errors of a 422 response
+	Body        []byte        // Raw response body
+}
+
+// newAPIError Create API error from response status, headers and body
+func newAPIError(statusCode int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		RequestID:  header.Get("X-Request-ID"),
+		RetryAfter: parseRetryAfter(header.Get("Retry-After")),
+		Body:       body,
+	}
+
+	var errorResp struct {
+		ID     string          `json:"id"`
+		Detail json.RawMessage `json:"detail"`
+	}
+	if json.Unmarshal(body, &errorResp) == nil {
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = errorResp.ID
+		}
+		apiErr.Detail, apiErr.FieldErrors = parseErrorDetail(errorResp.Detail)
+	}
+	if apiErr.Detail == "" {
+		apiErr.Detail = strings.TrimSpace(string(body))
+	}
+	if apiErr.Detail == "" {
+		apiErr.Detail = http.StatusText(statusCode)
+	}
+
+	apiErr.XiangxinAIError = &XiangxinAIError{
+		Message: fmt.Sprintf("API request failed with status %d: %s", statusCode, apiErr.Detail),
+	}
+	return apiErr
+}
+
+// parseErrorDetail Parse the detail field, which is a string, an object or a list of field errors
+func parseErrorDetail(raw json.RawMessage) (string, []FieldError) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	var detail string
+	if json.Unmarshal(raw, &detail) == nil {
+		return detail, nil
+	}
+
+	var fieldErrors []FieldError
+	if json.Unmarshal(raw, &fieldErrors) == nil && len(fieldErrors) > 0 {
+		messages := make([]string, 0, len(fieldErrors))
+		for _, fieldErr := range fieldErrors {
+			if field := fieldErr.Field(); field != "" {
+				messages = append(messages, fmt.Sprintf("%s: %s", field, fieldErr.Msg))
+			} else {
+				messages = append(messages, fieldErr.Msg)
+			}
+		}
+		return strings.Join(messages, "; "), fieldErrors
+	}
+
+	return string(raw), nil
+}
+
+// parseRetryAfter Parse Retry-After header, in seconds or HTTP date format
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
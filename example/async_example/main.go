@@ -11,7 +11,10 @@ import (
 
 func main() {
 	// 创建异步客户端
-	asyncClient := xiangxinai.NewAsyncClient("your-api-key")
+	asyncClient, err := xiangxinai.NewAsyncClient("your-api-key")
+	if err != nil {
+		log.Fatalf("初始化异步客户端失败: %v", err)
+	}
 	defer asyncClient.Close()
 	
 	ctx := context.Background()
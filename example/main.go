@@ -10,7 +10,10 @@ import (
 
 func main() {
 	// 初始化客户端
-	client := xiangxinai.NewClient("your-api-key")
+	client, err := xiangxinai.NewClient("your-api-key")
+	if err != nil {
+		log.Fatalf("初始化客户端失败: %v", err)
+	}
 	ctx := context.Background()
 
 	// 示例1: 检测单个提示词
@@ -0,0 +1,55 @@
+// Package guardecho adapts guardhttp to Echo.
+//
+// Example usage:
+//
+//	guard, err := guardhttp.New(guardhttp.Config{Client: client})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	e := echo.New()
+//	e.Use(guardecho.Middleware(guard))
+//	e.POST("/chat", func(c echo.Context) error {
+//		result, _ := guardecho.Result(c)
+//		// ...
+//	})
+package guardecho
+
+import (
+	"github.com/labstack/echo/v4"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/guardhttp"
+)
+
+// ContextKey Echo context key of the guardrail result
+const ContextKey = "guardrail_result"
+
+// Middleware Return Echo middleware checking requests with the guard
+//
+// The result is stored under ContextKey and in the request context.
+func Middleware(guard *guardhttp.Guard) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			result, err := guard.Check(req)
+			if err != nil {
+				guard.WriteError(c.Response(), req, err)
+				return nil
+			}
+			if result != nil {
+				if guard.Blocked(result) {
+					guard.WriteBlocked(c.Response(), req, result)
+					return nil
+				}
+				c.SetRequest(req.WithContext(xiangxinai.ContextWithResult(req.Context(), result)))
+				c.Set(ContextKey, result)
+			}
+			return next(c)
+		}
+	}
+}
+
+// Result Return the guardrail result of the request
+func Result(c echo.Context) (*xiangxinai.GuardrailResponse, bool) {
+	return xiangxinai.ResultFromContext(c.Request().Context())
+}
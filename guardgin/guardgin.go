@@ -0,0 +1,54 @@
+// Package guardgin adapts guardhttp to Gin.
+//
+// Example usage:
+//
+//	guard, err := guardhttp.New(guardhttp.Config{Client: client})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	r := gin.Default()
+//	r.Use(guardgin.Middleware(guard))
+//	r.POST("/chat", func(c *gin.Context) {
+//		result, _ := guardgin.Result(c)
+//		// ...
+//	})
+package guardgin
+
+import (
+	"github.com/gin-gonic/gin"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/guardhttp"
+)
+
+// ContextKey Gin context key of the guardrail result
+const ContextKey = "guardrail_result"
+
+// Middleware Return Gin middleware checking requests with the guard
+//
+// The result is stored under ContextKey and in the request context.
+func Middleware(guard *guardhttp.Guard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := guard.Check(c.Request)
+		if err != nil {
+			guard.WriteError(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		if result != nil {
+			if guard.Blocked(result) {
+				guard.WriteBlocked(c.Writer, c.Request, result)
+				c.Abort()
+				return
+			}
+			c.Request = c.Request.WithContext(xiangxinai.ContextWithResult(c.Request.Context(), result))
+			c.Set(ContextKey, result)
+		}
+		c.Next()
+	}
+}
+
+// Result Return the guardrail result of the request
+func Result(c *gin.Context) (*xiangxinai.GuardrailResponse, bool) {
+	return xiangxinai.ResultFromContext(c.Request.Context())
+}
@@ -0,0 +1,223 @@
+// Package guardgrpc provides gRPC server interceptors that check inbound
+// messages with Xiangxin AI Guardrails.
+//
+// The content to check is taken from each request message by a pluggable
+// Extractor, by default the first non-empty GetContent, GetPrompt, GetText or
+// GetInput string getter of generated protobuf messages. The result is stored
+// in the handler context (see xiangxinai.ResultFromContext); for streams the
+// context returned by ServerStream.Context carries the result of the last
+// received message.
+//
+// Example usage:
+//
+//	interceptor, err := guardgrpc.New(guardgrpc.Config{
+//		Client: client,
+//		UserID: guardgrpc.UserIDMetadata("x-user-id"),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(interceptor.Unary()),
+//		grpc.StreamInterceptor(interceptor.Stream()),
+//	)
+package guardgrpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/guardhttp"
+)
+
+// Extractor Extract the messages to check from a request message
+//
+// Returning no messages skips the check.
+type Extractor func(ctx context.Context, req interface{}) ([]*xiangxinai.Message, error)
+
+// UserIDExtractor Extract the end user ID passed as userID to the check
+type UserIDExtractor func(ctx context.Context) string
+
+// Config Interceptor configuration
+type Config struct {
+	Client      *xiangxinai.Client                                                    // Guardrail client (required)
+	Extractor   Extractor                                                             // Content extractor, default TextGetters()
+	UserID      UserIDExtractor                                                       // Optional user ID extractor
+	Model       string                                                                // Guardrail model, default xiangxinai.DefaultModel
+	Skip        func(ctx context.Context, fullMethod string) bool                     // Skip the check of a method
+	ShouldBlock func(result *xiangxinai.GuardrailResponse) bool                       // Block decision, default reject or replace
+	OnBlock     func(ctx context.Context, result *xiangxinai.GuardrailResponse) error // Block error, default PermissionDenied with the suggested answer
+	OnError     func(ctx context.Context, err error) error                            // Check error, default InvalidArgument for validation errors, Unavailable otherwise
+}
+
+// Interceptor Guardrail interceptor for gRPC servers
+type Interceptor struct {
+	config Config
+}
+
+// New Create new interceptor
+func New(config Config) (*Interceptor, error) {
+	if config.Client == nil {
+		return nil, xiangxinai.NewValidationError("guardrail client cannot be nil")
+	}
+	if config.Extractor == nil {
+		config.Extractor = TextGetters()
+	}
+	if config.Model == "" {
+		config.Model = xiangxinai.DefaultModel
+	}
+	if config.ShouldBlock == nil {
+		config.ShouldBlock = guardhttp.IsBlocking
+	}
+	if config.OnBlock == nil {
+		config.OnBlock = DefaultBlockHandler
+	}
+	if config.OnError == nil {
+		config.OnError = DefaultErrorHandler
+	}
+	return &Interceptor{config: config}, nil
+}
+
+// Unary Return a unary server interceptor
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := i.check(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream Return a stream server interceptor checking every received message
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &guardedStream{ServerStream: ss, interceptor: i, method: info.FullMethod, ctx: ss.Context()})
+	}
+}
+
+// check Check a request message, returning the context carrying the result
+func (i *Interceptor) check(ctx context.Context, method string, req interface{}) (context.Context, error) {
+	if i.config.Skip != nil && i.config.Skip(ctx, method) {
+		return ctx, nil
+	}
+
+	messages, err := i.config.Extractor(ctx, req)
+	if err != nil {
+		return ctx, i.config.OnError(ctx, err)
+	}
+	if len(messages) == 0 {
+		return ctx, nil
+	}
+
+	var userID string
+	if i.config.UserID != nil {
+		userID = i.config.UserID(ctx)
+	}
+	result, err := i.config.Client.CheckConversationWithModel(ctx, messages, i.config.Model, userID)
+	if err != nil {
+		return ctx, i.config.OnError(ctx, err)
+	}
+	if i.config.ShouldBlock(result) {
+		return ctx, i.config.OnBlock(ctx, result)
+	}
+	return xiangxinai.ContextWithResult(ctx, result), nil
+}
+
+// guardedStream Server stream checking received messages
+type guardedStream struct {
+	grpc.ServerStream
+	interceptor *Interceptor
+	method      string
+
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+// Context Return the stream context carrying the result of the last received message
+func (s *guardedStream) Context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
+
+// RecvMsg Receive and check a message
+func (s *guardedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	ctx, err := s.interceptor.check(s.ServerStream.Context(), s.method, m)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	return nil
+}
+
+// TextGetters Extract text from the first non-empty GetContent, GetPrompt, GetText or GetInput getter
+func TextGetters() Extractor {
+	return Text(func(req interface{}) string {
+		if v, ok := req.(interface{ GetContent() string }); ok && v.GetContent() != "" {
+			return v.GetContent()
+		}
+		if v, ok := req.(interface{ GetPrompt() string }); ok && v.GetPrompt() != "" {
+			return v.GetPrompt()
+		}
+		if v, ok := req.(interface{ GetText() string }); ok && v.GetText() != "" {
+			return v.GetText()
+		}
+		if v, ok := req.(interface{ GetInput() string }); ok && v.GetInput() != "" {
+			return v.GetInput()
+		}
+		return ""
+	})
+}
+
+// Text Extract text from a request message with a function
+func Text(fn func(req interface{}) string) Extractor {
+	return func(ctx context.Context, req interface{}) ([]*xiangxinai.Message, error) {
+		text := fn(req)
+		if strings.TrimSpace(text) == "" {
+			return nil, nil
+		}
+		return []*xiangxinai.Message{xiangxinai.NewMessage("user", text)}, nil
+	}
+}
+
+// UserIDMetadata Extract the user ID from incoming metadata
+func UserIDMetadata(key string) UserIDExtractor {
+	return func(ctx context.Context) string {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return ""
+		}
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+}
+
+// DefaultBlockHandler Return a PermissionDenied error with the suggested answer
+func DefaultBlockHandler(ctx context.Context, result *xiangxinai.GuardrailResponse) error {
+	return status.Error(codes.PermissionDenied, guardhttp.Answer(result))
+}
+
+// DefaultErrorHandler Return InvalidArgument for validation errors, Unavailable otherwise
+func DefaultErrorHandler(ctx context.Context, err error) error {
+	var validationErr *xiangxinai.ValidationError
+	if errors.As(err, &validationErr) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Errorf(codes.Unavailable, "guardrail check failed: %v", err)
+}
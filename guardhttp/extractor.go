@@ -0,0 +1,234 @@
+package guardhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+// defaultMaxMemory Maximum memory used to parse multipart forms
+const defaultMaxMemory = 32 << 20
+
+// ErrNotApplicable Returned by an extractor for requests it does not handle, e.g. a JSON extractor for a form post
+//
+// FirstOf then tries the next extractor; the guard skips the check if none applies.
+var ErrNotApplicable = errors.New("extractor does not apply to the request")
+
+// Extractor Extract the messages to check from a request
+//
+// body is the full request body; r.Body has already been restored and may be
+// read again. Returning no messages skips the check.
+type Extractor func(r *http.Request, body []byte) ([]*xiangxinai.Message, error)
+
+// UserIDExtractor Extract the end user ID passed as userID to the check
+type UserIDExtractor func(r *http.Request) string
+
+// JSONPath Extract text from a JSON body by a dotted path, e.g. "input.text" or "messages.0.content"
+//
+// The value must be a string or an array of strings, which are joined with
+// newlines. A missing value skips the check. Bodies that are not JSON, see
+// decodeJSON, return ErrNotApplicable.
+func JSONPath(path string) Extractor {
+	keys := strings.Split(path, ".")
+	return func(r *http.Request, body []byte) ([]*xiangxinai.Message, error) {
+		if len(bytes.TrimSpace(body)) == 0 {
+			return nil, nil
+		}
+		var value interface{}
+		if err := decodeJSON(r, body, &value, "invalid JSON body"); err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				value = v[key]
+			case []interface{}:
+				index, err := strconv.Atoi(key)
+				if err != nil || index < 0 || index >= len(v) {
+					return nil, nil
+				}
+				value = v[index]
+			default:
+				return nil, nil
+			}
+		}
+
+		text, err := jsonText(value)
+		if err != nil {
+			return nil, xiangxinai.NewValidationError(fmt.Sprintf("%s: %v", path, err))
+		}
+		return userMessage(text), nil
+	}
+}
+
+// OpenAIMessages Extract the "messages" array of an OpenAI chat completion request
+//
+// Only user, system and assistant messages are checked; developer messages are
+// treated as system messages and other roles (e.g. tool) are skipped. Bodies
+// that are not JSON, see decodeJSON, return ErrNotApplicable.
+func OpenAIMessages() Extractor {
+	return func(r *http.Request, body []byte) ([]*xiangxinai.Message, error) {
+		if len(bytes.TrimSpace(body)) == 0 {
+			return nil, nil
+		}
+		var req struct {
+			Messages []struct {
+				Role    string                    `json:"role"`
+				Content xiangxinai.MessageContent `json:"content"`
+			} `json:"messages"`
+		}
+		if err := decodeJSON(r, body, &req, "invalid chat completion request"); err != nil {
+			return nil, err
+		}
+
+		messages := make([]*xiangxinai.Message, 0, len(req.Messages))
+		for _, msg := range req.Messages {
+			role := msg.Role
+			if role == "developer" {
+				role = "system"
+			}
+			if role != "user" && role != "system" && role != "assistant" {
+				continue
+			}
+			messages = append(messages, &xiangxinai.Message{Role: role, Content: msg.Content})
+		}
+		return messages, nil
+	}
+}
+
+// FormField Extract text from a URL-encoded or multipart form field
+//
+// Requests of other content types return ErrNotApplicable.
+func FormField(name string) Extractor {
+	return func(r *http.Request, body []byte) ([]*xiangxinai.Message, error) {
+		mediaType := contentType(r)
+		if mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data" {
+			return nil, ErrNotApplicable
+		}
+
+		// Parse a copy so the original request keeps its body and form state
+		clone := r.Clone(r.Context())
+		clone.Body = io.NopCloser(bytes.NewReader(body))
+		clone.Form, clone.PostForm, clone.MultipartForm = nil, nil, nil
+
+		var err error
+		if mediaType == "multipart/form-data" {
+			err = clone.ParseMultipartForm(defaultMaxMemory)
+		} else {
+			err = clone.ParseForm()
+		}
+		if err != nil {
+			return nil, xiangxinai.NewValidationError(fmt.Sprintf("invalid form body: %v", err))
+		}
+		if clone.MultipartForm != nil {
+			defer clone.MultipartForm.RemoveAll()
+		}
+		return userMessage(strings.Join(clone.Form[name], "\n")), nil
+	}
+}
+
+// FirstOf Return the messages of the first extractor that finds any
+//
+// Extractors returning ErrNotApplicable are skipped; ErrNotApplicable is
+// returned if none of the extractors applies.
+func FirstOf(extractors ...Extractor) Extractor {
+	return func(r *http.Request, body []byte) ([]*xiangxinai.Message, error) {
+		applied := false
+		for _, extractor := range extractors {
+			messages, err := extractor(r, body)
+			if errors.Is(err, ErrNotApplicable) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			applied = true
+			if len(messages) > 0 {
+				return messages, nil
+			}
+		}
+		if !applied && len(extractors) > 0 {
+			return nil, ErrNotApplicable
+		}
+		return nil, nil
+	}
+}
+
+// UserIDHeader Extract the user ID from a request header
+func UserIDHeader(name string) UserIDExtractor {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// UserIDQuery Extract the user ID from a query parameter
+func UserIDQuery(name string) UserIDExtractor {
+	return func(r *http.Request) string {
+		return r.URL.Query().Get(name)
+	}
+}
+
+// decodeJSON Decode a JSON body into v
+//
+// Bodies of a non-JSON content type, and invalid bodies without a content
+// type, return ErrNotApplicable; invalid bodies declared as JSON return a
+// ValidationError.
+func decodeJSON(r *http.Request, body []byte, v interface{}, message string) error {
+	mediaType := contentType(r)
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	if mediaType != "" && !isJSON {
+		return ErrNotApplicable
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		if !isJSON {
+			return ErrNotApplicable
+		}
+		return xiangxinai.NewValidationError(fmt.Sprintf("%s: %v", message, err))
+	}
+	return nil
+}
+
+// contentType Return the media type of the request, empty if not set
+func contentType(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return strings.ToLower(mediaType)
+}
+
+// jsonText Convert a JSON string or array of strings to text
+func jsonText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("expected string or array of strings")
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, "\n"), nil
+	default:
+		return "", fmt.Errorf("expected string or array of strings")
+	}
+}
+
+// userMessage Return text as a single user message, nil if blank
+func userMessage(text string) []*xiangxinai.Message {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	return []*xiangxinai.Message{xiangxinai.NewMessage("user", text)}
+}
@@ -0,0 +1,239 @@
+// Package guardhttp provides net/http middleware that checks inbound requests
+// with Xiangxin AI Guardrails.
+//
+// The content to check is taken from the request by a pluggable Extractor
+// (OpenAI messages, a JSON path or a form field). The body is restored after
+// reading so downstream handlers can read it again, and the result is stored
+// in the request context (see xiangxinai.ResultFromContext). Blocked requests
+// and check errors are answered by configurable handlers.
+//
+// Example usage:
+//
+//	guard, err := guardhttp.New(guardhttp.Config{
+//		Client:    client,
+//		Extractor: guardhttp.JSONPath("content"),
+//		UserID:    guardhttp.UserIDHeader("X-User-ID"),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	http.Handle("/chat", guard.Middleware(chatHandler))
+//
+// The guardgin, guardecho and guardgrpc packages adapt the same Guard to Gin,
+// Echo and gRPC.
+package guardhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+const (
+	// DefaultMaxBodyBytes Default maximum request body size
+	DefaultMaxBodyBytes = 10 << 20
+	// DefaultBlockedMessage Message of the default block response when no suggested answer is returned
+	DefaultBlockedMessage = "Content blocked by guardrail"
+)
+
+// BlockHandler Write the response for a blocked request
+type BlockHandler func(w http.ResponseWriter, r *http.Request, result *xiangxinai.GuardrailResponse)
+
+// ErrorHandler Write the response for a failed check
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Config Middleware configuration
+type Config struct {
+	Client       *xiangxinai.Client                              // Guardrail client (required)
+	Extractor    Extractor                                       // Content extractor, default OpenAI messages, then JSON "content"
+	UserID       UserIDExtractor                                 // Optional user ID extractor
+	Model        string                                          // Guardrail model, default xiangxinai.DefaultModel
+	MaxBodyBytes int64                                           // Maximum body size, default DefaultMaxBodyBytes
+	Skip         func(r *http.Request) bool                      // Skip the check, default skips GET, HEAD and OPTIONS
+	ShouldBlock  func(result *xiangxinai.GuardrailResponse) bool // Block decision, default reject or replace
+	OnBlock      BlockHandler                                    // Block response, default 403 JSON with the suggested answer
+	OnError      ErrorHandler                                    // Error response, default 400 for validation errors, 502 otherwise
+}
+
+// Guard Request guard shared by the framework adapters
+type Guard struct {
+	config Config
+}
+
+// New Create new guard
+func New(config Config) (*Guard, error) {
+	if config.Client == nil {
+		return nil, xiangxinai.NewValidationError("guardrail client cannot be nil")
+	}
+	if config.Extractor == nil {
+		config.Extractor = FirstOf(OpenAIMessages(), JSONPath("content"))
+	}
+	if config.Model == "" {
+		config.Model = xiangxinai.DefaultModel
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if config.Skip == nil {
+		config.Skip = skipBodyless
+	}
+	if config.ShouldBlock == nil {
+		config.ShouldBlock = IsBlocking
+	}
+	if config.OnBlock == nil {
+		config.OnBlock = DefaultBlockHandler
+	}
+	if config.OnError == nil {
+		config.OnError = DefaultErrorHandler
+	}
+	return &Guard{config: config}, nil
+}
+
+// Middleware Wrap an http.Handler
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := g.Check(r)
+		if err != nil {
+			g.config.OnError(w, r, err)
+			return
+		}
+		if result != nil {
+			if g.config.ShouldBlock(result) {
+				g.config.OnBlock(w, r, result)
+				return
+			}
+			r = r.WithContext(xiangxinai.ContextWithResult(r.Context(), result))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check Check the request, restoring its body
+//
+// Returns a nil result if the request is skipped, has nothing to check or is
+// not handled by the extractor, e.g. a form post with the default extractor.
+func (g *Guard) Check(r *http.Request) (*xiangxinai.GuardrailResponse, error) {
+	if g.config.Skip(r) {
+		return nil, nil
+	}
+
+	body, err := readBody(r, g.config.MaxBodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := g.config.Extractor(r, body)
+	if errors.Is(err, ErrNotApplicable) {
+		return nil, nil
+	}
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+
+	var userID string
+	if g.config.UserID != nil {
+		userID = g.config.UserID(r)
+	}
+	return g.config.Client.CheckConversationWithModel(r.Context(), messages, g.config.Model, userID)
+}
+
+// Blocked Check if the result blocks the request
+func (g *Guard) Blocked(result *xiangxinai.GuardrailResponse) bool {
+	return result != nil && g.config.ShouldBlock(result)
+}
+
+// WriteBlocked Write the configured block response
+func (g *Guard) WriteBlocked(w http.ResponseWriter, r *http.Request, result *xiangxinai.GuardrailResponse) {
+	g.config.OnBlock(w, r, result)
+}
+
+// WriteError Write the configured error response
+func (g *Guard) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	g.config.OnError(w, r, err)
+}
+
+// IsBlocking Check if the final action is reject or replace
+func IsBlocking(result *xiangxinai.GuardrailResponse) bool {
+	action := result.FinalAction()
+	return action == xiangxinai.ActionReject || action == xiangxinai.ActionReplace
+}
+
+// Answer Return the suggested answer of a blocked result, honoring the local policy
+func Answer(result *xiangxinai.GuardrailResponse) string {
+	return result.FinalAnswer(DefaultBlockedMessage)
+}
+
+// DefaultBlockHandler Write a 403 JSON response with the suggested answer
+func DefaultBlockHandler(w http.ResponseWriter, r *http.Request, result *xiangxinai.GuardrailResponse) {
+	writeJSON(w, http.StatusForbidden, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":        Answer(result),
+			"type":           "guardrail_blocked",
+			"guardrail_id":   result.ID,
+			"suggest_action": result.FinalAction(),
+			"risk_level":     result.OverallRiskLevel,
+			"categories":     result.GetAllCategories(),
+		},
+	})
+}
+
+// DefaultErrorHandler Write a 400 JSON response for validation errors, 502 otherwise
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	status, errType := ErrorStatus(err)
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": err.Error(),
+			"type":    errType,
+		},
+	})
+}
+
+// ErrorStatus Map a check error to an HTTP status code and error type
+func ErrorStatus(err error) (int, string) {
+	var validationErr *xiangxinai.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest, "invalid_request_error"
+	}
+	return http.StatusBadGateway, "guardrail_error"
+}
+
+// readBody Read the request body and restore it for downstream handlers
+func readBody(r *http.Request, maxBytes int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, xiangxinai.NewValidationError(fmt.Sprintf("failed to read request body: %v", err))
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, xiangxinai.NewValidationError("request body too large")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// skipBodyless Skip requests that have no body to check
+func skipBodyless(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// writeJSON Write a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,381 @@
+package xiangxinai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Register the GIF decoder for downscaling
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// DefaultImageMaxBytes Default maximum size of an uploaded image
+	DefaultImageMaxBytes = 10 << 20
+	// DefaultImageMaxSourceBytes Default maximum size of a source image read for downscaling or recompression
+	DefaultImageMaxSourceBytes = 64 << 20
+	// DefaultImageJPEGQuality Default quality of recompressed JPEG images
+	DefaultImageJPEGQuality = 85
+	// DefaultImageMaxPixels Default maximum width × height of an image decoded for downscaling or recompression
+	DefaultImageMaxPixels = 50_000_000
+)
+
+// supportedImageTypes MIME types accepted by the image model
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+}
+
+// ImageConfig Image upload configuration, used with WithImageConfig
+//
+// Images are inlined as base64 data URLs labelled with the MIME type sniffed
+// from their content. Images larger than MaxBytes are rejected with a
+// ValidationError unless Recompress is set. JPEG, PNG and GIF images can be
+// downscaled and recompressed; WebP and BMP images are uploaded as-is. Images
+// are only decoded if their header reports at most MaxPixels pixels, as a
+// small compressed file can expand to gigabytes of pixels.
+type ImageConfig struct {
+	MaxBytes       int64 // Maximum uploaded image size, default 10 MiB
+	MaxSourceBytes int64 // Maximum source image size when MaxDimension or Recompress is set, default 64 MiB
+	MaxDimension   int   // Downscale images whose width or height exceeds this many pixels, 0 disables
+	Recompress     bool  // Recompress images larger than MaxBytes as JPEG instead of rejecting them
+	JPEGQuality    int   // Quality of recompressed JPEG images, default 85
+	MaxPixels      int64 // Maximum width × height of an image decoded for MaxDimension or Recompress, default 50 million
+}
+
+// ImageInput Image source of an image check
+//
+// Create inputs with ImageFromFile, ImageFromBytes, ImageFromReader,
+// ImageFromDataURL, ImageFromURL or ImageFromURLInline.
+type ImageInput struct {
+	source string // Description used in errors
+	url    string // Remote URL passed to the server unchanged
+	load   func(ctx context.Context, c *Client, limit int64) ([]byte, error)
+}
+
+// String Return a description of the image source
+func (in ImageInput) String() string {
+	return in.source
+}
+
+// ImageFromFile Create image input reading a local file
+func ImageFromFile(path string) ImageInput {
+	return ImageInput{
+		source: path,
+		load: func(ctx context.Context, c *Client, limit int64) ([]byte, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil, NewValidationError(fmt.Sprintf("image file not found: %s", path))
+				}
+				return nil, err
+			}
+			defer f.Close()
+			return readImage(f, limit)
+		},
+	}
+}
+
+// ImageFromBytes Create image input from raw image bytes
+func ImageFromBytes(data []byte) ImageInput {
+	return ImageInput{
+		source: fmt.Sprintf("image bytes (%d bytes)", len(data)),
+		load: func(ctx context.Context, c *Client, limit int64) ([]byte, error) {
+			if int64(len(data)) > limit {
+				return nil, imageTooLargeError(int64(len(data)), limit)
+			}
+			return data, nil
+		},
+	}
+}
+
+// ImageFromReader Create image input reading r, which is consumed by the first check using it
+func ImageFromReader(r io.Reader) ImageInput {
+	return ImageInput{
+		source: "image reader",
+		load: func(ctx context.Context, c *Client, limit int64) ([]byte, error) {
+			return readImage(r, limit)
+		},
+	}
+}
+
+// ImageFromDataURL Create image input from a base64 data URL, e.g. data:image/png;base64,...
+//
+// The image is decoded to validate its format and size, and relabelled with
+// the sniffed MIME type if the URL's type is wrong.
+func ImageFromDataURL(dataURL string) ImageInput {
+	return ImageInput{
+		source: "data URL",
+		load: func(ctx context.Context, c *Client, limit int64) ([]byte, error) {
+			return decodeDataURL(dataURL, limit)
+		},
+	}
+}
+
+// ImageFromURL Create image input passing a http(s) URL to the server, which fetches the image itself
+func ImageFromURL(url string) ImageInput {
+	return ImageInput{source: url, url: url}
+}
+
+// ImageFromURLInline Create image input fetching a http(s) URL on the client and inlining it
+//
+// Use it for URLs the server cannot reach, e.g. on a private network. The
+// image is fetched with the client's HTTP client, honoring its proxy and TLS
+// settings.
+func ImageFromURLInline(url string) ImageInput {
+	return ImageInput{
+		source: url,
+		load: func(ctx context.Context, c *Client, limit int64) ([]byte, error) {
+			return c.fetchImage(ctx, url, limit)
+		},
+	}
+}
+
+// ImageFromPath Create image input from a local path, data URL or http(s) URL
+//
+// http(s) URLs are fetched by the client and inlined, as CheckPromptImage does.
+func ImageFromPath(path string) ImageInput {
+	switch {
+	case strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://"):
+		return ImageFromURLInline(path)
+	case strings.HasPrefix(path, "data:"):
+		return ImageFromDataURL(path)
+	default:
+		return ImageFromFile(path)
+	}
+}
+
+// imagePart Build the content part of an image input
+func (c *Client) imagePart(ctx context.Context, in ImageInput) (*ImageURLPart, error) {
+	if in.url != "" {
+		if !strings.HasPrefix(in.url, "http://") && !strings.HasPrefix(in.url, "https://") {
+			return nil, NewValidationError(fmt.Sprintf("image URL must be http(s): %s", in.url))
+		}
+		return NewImageURLPart(in.url), nil
+	}
+	if in.load == nil {
+		return nil, NewValidationError("image input cannot be empty")
+	}
+
+	config := c.imageConfig
+	limit := config.MaxBytes
+	if config.MaxDimension > 0 || config.Recompress {
+		limit = config.MaxSourceBytes
+	}
+	data, err := in.load(ctx, c, limit)
+	if err != nil {
+		return nil, err
+	}
+	data, mimeType, err := prepareImage(data, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewImageURLPart("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// imageParts Build the content parts of a prompt and images
+func (c *Client) imageParts(ctx context.Context, prompt string, images []ImageInput) ([]ContentPart, error) {
+	var parts []ContentPart
+	if strings.TrimSpace(prompt) != "" {
+		parts = append(parts, NewTextPart(strings.TrimSpace(prompt)))
+	}
+	for _, in := range images {
+		part, err := c.imagePart(ctx, in)
+		if err != nil {
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				return nil, err
+			}
+			return nil, NewXiangxinAIError(fmt.Sprintf("failed to encode image %s: %v", in, err), err)
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// fetchImage Download an image with the client's HTTP client
+func (c *Client) fetchImage(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image from URL: %w", err)
+	}
+	resp, err := c.client.GetClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image from URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch image: status %d", resp.StatusCode)
+	}
+	return readImage(resp.Body, limit)
+}
+
+// readImage Read an image of at most limit bytes
+func readImage(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, imageTooLargeError(-1, limit)
+	}
+	return data, nil
+}
+
+// decodeDataURL Decode a base64 data URL
+func decodeDataURL(dataURL string, limit int64) ([]byte, error) {
+	header, payload, ok := strings.Cut(dataURL, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, NewValidationError("image data URL must have the form data:<type>;base64,<data>")
+	}
+	if int64(base64.StdEncoding.DecodedLen(len(payload))) > limit+2 {
+		return nil, imageTooLargeError(int64(base64.StdEncoding.DecodedLen(len(payload))), limit)
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, NewValidationError(fmt.Sprintf("invalid base64 in image data URL: %v", err))
+	}
+	if int64(len(data)) > limit {
+		return nil, imageTooLargeError(int64(len(data)), limit)
+	}
+	return data, nil
+}
+
+// imageTooLargeError Return the error of an image exceeding the size limit, size is -1 if unknown
+func imageTooLargeError(size, limit int64) error {
+	if size < 0 {
+		return NewValidationError(fmt.Sprintf("image is larger than the maximum of %d bytes", limit))
+	}
+	return NewValidationError(fmt.Sprintf("image is %d bytes, larger than the maximum of %d bytes", size, limit))
+}
+
+// prepareImage Validate the image format, downscaling and recompressing it as configured
+func prepareImage(data []byte, config ImageConfig) ([]byte, string, error) {
+	mimeType := http.DetectContentType(data)
+	if !supportedImageTypes[mimeType] {
+		return nil, "", NewValidationError(fmt.Sprintf("unsupported image format: %s (supported: JPEG, PNG, GIF, WebP, BMP)", mimeType))
+	}
+
+	// Read the dimensions from the header before anything is decoded
+	size, _, sizeErr := image.DecodeConfig(bytes.NewReader(data))
+	tooLarge := int64(len(data)) > config.MaxBytes
+	tooWide := sizeErr == nil && config.MaxDimension > 0 && (size.Width > config.MaxDimension || size.Height > config.MaxDimension)
+	if !tooLarge && !tooWide {
+		return data, mimeType, nil
+	}
+	if tooLarge && !config.Recompress {
+		return nil, "", imageTooLargeError(int64(len(data)), config.MaxBytes)
+	}
+	cannotRecompress := NewValidationError(fmt.Sprintf("image is %d bytes, larger than the maximum of %d bytes, and %s images cannot be recompressed", len(data), config.MaxBytes, mimeType))
+	if sizeErr != nil {
+		return nil, "", cannotRecompress
+	}
+	if pixels := int64(size.Width) * int64(size.Height); config.MaxPixels > 0 && pixels > config.MaxPixels {
+		return nil, "", NewValidationError(fmt.Sprintf("image is %dx%d pixels, more than the maximum of %d pixels decoded for downscaling or recompression", size.Width, size.Height, config.MaxPixels))
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if tooLarge {
+			return nil, "", cannotRecompress
+		}
+		return data, mimeType, nil
+	}
+	if tooWide {
+		img = downscaleImage(img, config.MaxDimension)
+	}
+
+	// Keep PNG lossless unless it is still too large
+	if mimeType == "image/png" {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err == nil && (int64(buf.Len()) <= config.MaxBytes || !config.Recompress) {
+			if int64(buf.Len()) > config.MaxBytes {
+				return nil, "", imageTooLargeError(int64(buf.Len()), config.MaxBytes)
+			}
+			return buf.Bytes(), mimeType, nil
+		}
+	}
+
+	// Recompress as JPEG, lowering the quality and then the size until it fits
+	img = flattenImage(img)
+	quality := config.JPEGQuality
+	for attempt := 0; attempt < 6; attempt++ {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", NewXiangxinAIError("failed to recompress image", err)
+		}
+		if int64(buf.Len()) <= config.MaxBytes {
+			return buf.Bytes(), "image/jpeg", nil
+		}
+		if quality > 60 {
+			quality = 60
+		} else {
+			bounds := img.Bounds()
+			img = downscaleImage(img, maxInt(bounds.Dx(), bounds.Dy())/2)
+		}
+	}
+	return nil, "", NewValidationError(fmt.Sprintf("image could not be recompressed below the maximum of %d bytes", config.MaxBytes))
+}
+
+// downscaleImage Scale the image so neither side exceeds maxDimension, averaging source pixels
+func downscaleImage(src image.Image, maxDimension int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	longest := maxInt(width, height)
+	if maxDimension <= 0 || longest <= maxDimension {
+		return src
+	}
+	newWidth := maxInt(1, width*maxDimension/longest)
+	newHeight := maxInt(1, height*maxDimension/longest)
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := maxInt(y0+1, bounds.Min.Y+(y+1)*height/newHeight)
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := maxInt(x0+1, bounds.Min.X+(x+1)*width/newWidth)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// flattenImage Draw the image over a white background, as JPEG has no transparency
+func flattenImage(src image.Image) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// maxInt Return the larger of two ints
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
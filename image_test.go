@@ -0,0 +1,96 @@
+package xiangxinai_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// pngImage Encode a uniform PNG image of the given size
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// withPNGSize Rewrite the dimensions in the header of a PNG image
+func withPNGSize(data []byte, width, height uint32) []byte {
+	data = append([]byte(nil), data...)
+	// Signature (8), IHDR length (4) and type (4), then width and height
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+// uploadedImage Return the decoded image of the last check request
+func uploadedImage(t *testing.T, server *xiangxintest.Server) image.Config {
+	t.Helper()
+	req, ok := server.LastRequest()
+	require.True(t, ok)
+	var dataURL string
+	for _, msg := range req.Messages {
+		for _, part := range msg.Content.Parts {
+			if part, ok := part.(*xiangxinai.ImageURLPart); ok {
+				dataURL = part.ImageURL.URL
+			}
+		}
+	}
+	_, payload, ok := strings.Cut(dataURL, ",")
+	require.True(t, ok, "no inline image in %q", dataURL)
+	data, err := base64.StdEncoding.DecodeString(payload)
+	require.NoError(t, err)
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	return config
+}
+
+func TestImageDownscale(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithImageConfig(xiangxinai.ImageConfig{MaxDimension: 16}))
+
+	_, err := client.CheckImages(context.Background(), "describe", []xiangxinai.ImageInput{xiangxinai.ImageFromBytes(pngImage(t, 64, 32))})
+	require.NoError(t, err)
+	config := uploadedImage(t, server)
+	assert.Equal(t, 16, config.Width)
+	assert.Equal(t, 8, config.Height)
+}
+
+func TestImageRejectsPixelBomb(t *testing.T) {
+	// A few hundred bytes claiming 100000 × 100000 pixels
+	bomb := withPNGSize(pngImage(t, 8, 8), 100000, 100000)
+
+	for name, config := range map[string]xiangxinai.ImageConfig{
+		"downscale":  {MaxDimension: 1024},
+		"recompress": {MaxBytes: 64, Recompress: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, server := xiangxintest.NewClient(t, xiangxinai.WithImageConfig(config))
+			_, err := client.CheckImages(context.Background(), "describe", []xiangxinai.ImageInput{xiangxinai.ImageFromBytes(bomb)})
+			var validationErr *xiangxinai.ValidationError
+			require.True(t, errors.As(err, &validationErr), "got %v", err)
+			assert.Contains(t, err.Error(), "pixels")
+			assert.Zero(t, server.RequestCount("/guardrails"))
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package xiangxinai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Request Check request passed through the interceptor chain
+//
+// Body is the typed request: *GuardrailRequest for the /guardrails endpoint
+// (conversations, images and batch fallbacks), *InputRequest for
+// /guardrails/input, *OutputRequest for /guardrails/output and *BatchRequest
+// for /guardrails/batch, which only BatchInterceptor sees. Interceptors may
+// modify the body or replace it with one of the same type.
+type Request struct {
+	Method   string      // HTTP method, POST
+	Endpoint string      // API endpoint, e.g. /guardrails/input
+	Body     interface{} // Typed request body
+	Header   http.Header // Extra HTTP headers sent with this request
+
+	key string // Cache and coalescing key, computed once the request reaches the cache
+}
+
+// Next Continue the interceptor chain, sending the request
+type Next func(ctx context.Context, req *Request) (*GuardrailResponse, error)
+
+// Interceptor Wrap Check* requests, set with WithInterceptors
+//
+// An interceptor may modify the request before calling next, return a response
+// without calling next, or post-process the response. Interceptors run in the
+// order they are given, outside the local redaction and the response cache
+// and inside the local policy: interceptors see the original content, cached
+// responses reflect the modified request and policy decisions reflect the
+// post-processed response.
+//
+// Chunks of CheckBatch items sent to the batch endpoint are passed to
+// BatchInterceptor instead, since their response has one verdict per item.
+//
+// Example:
+//
+//	stripSystemPrompt := func(ctx context.Context, req *xiangxinai.Request, next xiangxinai.Next) (*xiangxinai.GuardrailResponse, error) {
+//		req.Header.Set("X-Tenant-ID", tenantFromContext(ctx))
+//		if body, ok := req.Body.(*xiangxinai.GuardrailRequest); ok && len(body.Messages) > 1 && body.Messages[0].Role == "system" {
+//			body.Messages = body.Messages[1:]
+//		}
+//		return next(ctx, req)
+//	}
+//	client, err := xiangxinai.NewClient("your-api-key", xiangxinai.WithInterceptors(stripSystemPrompt))
+type Interceptor func(ctx context.Context, req *Request, next Next) (*GuardrailResponse, error)
+
+// BatchNext Continue the batch interceptor chain, sending the request
+type BatchNext func(ctx context.Context, req *Request) (*BatchResponse, error)
+
+// BatchInterceptor Wrap the batch requests of CheckBatch, set with WithBatchInterceptors
+//
+// Each chunk of CheckBatch items sent to the batch endpoint passes through
+// the chain as one request with a *BatchRequest body, and its response carries
+// the result of every item. Batch interceptors run at the same place in the
+// chain as Interceptor, which does not see batch requests; the policy and
+// redaction apply per item. The response cache and request coalescing skip
+// batch requests.
+//
+// Example:
+//
+//	audit := func(ctx context.Context, req *xiangxinai.Request, next xiangxinai.BatchNext) (*xiangxinai.BatchResponse, error) {
+//		resp, err := next(ctx, req)
+//		if err == nil {
+//			for id, item := range resp.Results {
+//				if item.Err == nil {
+//					auditLog.Record(id, item.Result.FinalAction())
+//				}
+//			}
+//		}
+//		return resp, err
+//	}
+//	client, err := xiangxinai.NewClient("your-api-key", xiangxinai.WithBatchInterceptors(audit))
+type BatchInterceptor func(ctx context.Context, req *Request, next BatchNext) (*BatchResponse, error)
+
+// singleInterceptor Adapt an Interceptor to the chain, passing batch requests through
+func singleInterceptor(interceptor Interceptor) Interceptor {
+	return func(ctx context.Context, req *Request, next Next) (*GuardrailResponse, error) {
+		if req.Endpoint == batchEndpoint {
+			return next(ctx, req)
+		}
+		return interceptor(ctx, req, next)
+	}
+}
+
+// batchInterceptor Adapt a BatchInterceptor to the chain, passing other requests through
+func batchInterceptor(interceptor BatchInterceptor) Interceptor {
+	return func(ctx context.Context, req *Request, next Next) (*GuardrailResponse, error) {
+		if req.Endpoint != batchEndpoint {
+			return next(ctx, req)
+		}
+		batch, err := interceptor(ctx, req, func(ctx context.Context, req *Request) (*BatchResponse, error) {
+			result, err := next(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return result.batch, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if batch == nil {
+			batch = &BatchResponse{}
+		}
+		return &GuardrailResponse{batch: batch}, nil
+	}
+}
+
+// chainInterceptors Wrap handler in the interceptors, the first being the outermost
+func chainInterceptors(handler Next, interceptors ...Interceptor) Next {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req *Request) (*GuardrailResponse, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return handler
+}
+
+// buildHandler Build the request handler: telemetry and logging, policy, user interceptors, redaction, cache, then the API call
+func (c *Client) buildHandler(interceptors []Interceptor, batchInterceptors []BatchInterceptor) Next {
+	var chain []Interceptor
+	if c.telemetry != nil || c.log != nil {
+		chain = append(chain, c.observeInterceptor)
+	}
+	if c.policy != nil {
+		chain = append(chain, c.policyInterceptor)
+	}
+	for _, interceptor := range interceptors {
+		chain = append(chain, singleInterceptor(interceptor))
+	}
+	for _, interceptor := range batchInterceptors {
+		chain = append(chain, batchInterceptor(interceptor))
+	}
+	if c.redactor != nil {
+		chain = append(chain, c.redactInterceptor)
+	}
+	if c.cache != nil {
+		chain = append(chain, c.cacheInterceptor)
+	}
+	return chainInterceptors(c.check, chain...)
+}
+
+// observeInterceptor Record the span and metrics, and log the start and end of a check
+func (c *Client) observeInterceptor(ctx context.Context, req *Request, next Next) (*GuardrailResponse, error) {
+	ctx, stats := withCallStats(ctx)
+	var call *checkCall
+	if c.telemetry != nil {
+		ctx, call = c.telemetry.start(ctx, req.Endpoint, requestModel(req.Body), stats)
+		if batch, ok := req.Body.(*BatchRequest); ok {
+			call.span.SetAttributes(AttrBatchSize.Int(len(batch.Items)))
+		}
+	}
+	if c.log != nil {
+		c.log.requestStarted(ctx, req.Method, req.Endpoint, req.Body)
+	}
+	start := time.Now()
+	result, err := next(ctx, req)
+	if call != nil {
+		if req.Endpoint == batchEndpoint {
+			call.endBatch(ctx, result, err)
+		} else {
+			call.end(ctx, result, err)
+		}
+	}
+	if c.log != nil {
+		c.log.requestFinished(ctx, req.Endpoint, stats, time.Since(start), result, err)
+	}
+	return result, err
+}
+
+// policyInterceptor Attach the local policy decision to the response
+func (c *Client) policyInterceptor(ctx context.Context, req *Request, next Next) (*GuardrailResponse, error) {
+	result, err := next(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Endpoint == batchEndpoint {
+		results := result.batchResults()
+		for id, item := range results {
+			if item.Result != nil {
+				item.Result = c.applyPolicy(item.Result)
+				results[id] = item
+			}
+		}
+		return result, nil
+	}
+	return c.applyPolicy(result), nil
+}
+
+// cacheInterceptor Serve responses from the response cache, and cache new cacheable responses
+func (c *Client) cacheInterceptor(ctx context.Context, req *Request, next Next) (*GuardrailResponse, error) {
+	if req.Endpoint == batchEndpoint {
+		return next(ctx, req)
+	}
+	key, err := req.cacheKey(c.cache.scope)
+	if err != nil {
+		return nil, err
+	}
+	if body, ok := c.cache.cache.Get(ctx, key); ok {
+		var result GuardrailResponse
+		if err := json.Unmarshal(body, &result); err == nil {
+			result.Cached = true
+			if c.log != nil {
+				c.log.cacheHit(ctx, req.Endpoint)
+			}
+			return &result, nil
+		}
+	}
+
+	result, err := next(ctx, req)
+	if err != nil || result.Degraded || !c.cache.shouldCache(result) {
+		return result, err
+	}
+	if body, err := json.Marshal(result); err == nil {
+		c.cache.cache.Set(ctx, key, body, c.cache.ttl)
+		if c.log != nil {
+			c.log.cacheStored(ctx, req.Endpoint, c.cache.ttl)
+		}
+	}
+	return result, nil
+}
+
+// cacheKey Return the cache and coalescing key of the request
+//
+// scope identifies the API key and base URL of the client, see cacheScope, so
+// clients sharing a Cache never see each other's responses.
+func (r *Request) cacheKey(scope string) (string, error) {
+	if r.key != "" {
+		return r.key, nil
+	}
+	body, err := json.Marshal(r.Body)
+	if err != nil {
+		return "", NewXiangxinAIError("failed to encode request", err)
+	}
+	hash := sha256.New()
+	hash.Write([]byte(scope))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.Endpoint))
+	hash.Write([]byte{0})
+	hash.Write(body)
+
+	// Headers may select a tenant, so they are part of the key
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range r.Header[name] {
+			hash.Write([]byte{0})
+			hash.Write([]byte(name + ": " + value))
+		}
+	}
+	r.key = "xiangxinai:" + hex.EncodeToString(hash.Sum(nil))
+	return r.key, nil
+}
+
+// requestHeaderContextKey Context key of the extra headers of a request
+type requestHeaderContextKey struct{}
+
+// withRequestHeader Return a copy of ctx carrying the extra headers sent by doWithRetry
+func withRequestHeader(ctx context.Context, header http.Header) context.Context {
+	if len(header) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestHeaderContextKey{}, header)
+}
+
+// requestHeader Return the extra headers stored in ctx
+func requestHeader(ctx context.Context) http.Header {
+	header, _ := ctx.Value(requestHeaderContextKey{}).(http.Header)
+	return header
+}
@@ -0,0 +1,271 @@
+package xiangxinai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// DefaultLogMaxBodyBytes Default maximum logged length of response bodies and request content
+const DefaultLogMaxBodyBytes = 512
+
+// redactedText Replacement of redacted values in log records
+const redactedText = "[REDACTED]"
+
+// Logger Structured logger used by the client, set with WithLogger or WithLogging
+//
+// The methods match those of *slog.Logger, so a *slog.Logger can be passed
+// directly. args are alternating keys and values:
+//
+//	client, err := xiangxinai.NewClient("your-api-key", xiangxinai.WithLogger(slog.Default()))
+type Logger interface {
+	DebugContext(ctx context.Context, msg string, args ...interface{})
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+// LogConfig Logging settings, used with WithLogging
+//
+// The client logs the start and end of each check at debug level, failed
+// attempts with their reason, response body and backoff at warn level, and
+// cache, circuit breaker and degraded response events. The API key is never
+// logged. Request content is only logged at debug level if LogContent is set.
+// Otherwise the content fields of logged response bodies and API error
+// details, e.g. content, input, output and text, are masked wherever they
+// appear, as the server may echo the request; bodies that are not JSON are
+// replaced as a whole.
+type LogConfig struct {
+	Logger       Logger              // Log destination, required
+	LogContent   bool                // Log request content at debug level, default false
+	MaxBodyBytes int                 // Maximum logged length of bodies and content, default DefaultLogMaxBodyBytes
+	Redact       func(string) string // Optional hook applied to every logged body, content and error message
+}
+
+// clientLogger Logger of a client, applying the redaction settings
+type clientLogger struct {
+	logger       Logger
+	logContent   bool
+	maxBodyBytes int
+	redact       func(string) string
+	apiKey       string
+}
+
+// newClientLogger Create the client logger, filling in defaults
+func newClientLogger(config LogConfig, apiKey string) *clientLogger {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultLogMaxBodyBytes
+	}
+	return &clientLogger{
+		logger:       config.Logger,
+		logContent:   config.LogContent,
+		maxBodyBytes: config.MaxBodyBytes,
+		redact:       config.Redact,
+		apiKey:       apiKey,
+	}
+}
+
+// sanitize Mask the API key in s, apply the redaction hook and truncate
+func (l *clientLogger) sanitize(s string) string {
+	if l.apiKey != "" {
+		s = strings.ReplaceAll(s, l.apiKey, redactedText)
+	}
+	if l.redact != nil {
+		s = l.redact(s)
+	}
+	return truncateLog(s, l.maxBodyBytes)
+}
+
+// bodyText Return the sanitized text of a response body, masking its content fields unless content logging is enabled
+func (l *clientLogger) bodyText(body []byte) string {
+	if !l.logContent {
+		body = maskContent(body)
+	}
+	return l.sanitize(string(body))
+}
+
+// errorText Return the sanitized message of err
+//
+// The detail of an API error is parsed again from the masked response body,
+// as it may echo the request content.
+func (l *clientLogger) errorText(err error) string {
+	text := err.Error()
+	var apiErr *APIError
+	if !l.logContent && errors.As(err, &apiErr) {
+		masked := newAPIError(apiErr.StatusCode, http.Header{}, maskContent(apiErr.Body))
+		text = strings.Replace(text, apiErr.Error(), masked.Error(), 1)
+	}
+	return l.sanitize(text)
+}
+
+// requestStarted Log the start of a check request
+func (l *clientLogger) requestStarted(ctx context.Context, method, endpoint string, requestData interface{}) {
+	args := []interface{}{"method", method, "endpoint", endpoint}
+	if model := requestModel(requestData); model != "" {
+		args = append(args, "model", model)
+	}
+	if l.logContent {
+		if body, err := json.Marshal(requestData); err == nil {
+			args = append(args, "content", l.sanitize(string(body)))
+		}
+	}
+	l.logger.DebugContext(ctx, "guardrail request started", args...)
+}
+
+// requestFinished Log the end of a check request
+func (l *clientLogger) requestFinished(ctx context.Context, endpoint string, stats *callStats, elapsed time.Duration, resp *GuardrailResponse, err error) {
+	args := []interface{}{"endpoint", endpoint, "attempts", stats.attemptCount(), "duration", elapsed}
+	if errors.Is(err, ErrBatchUnsupported) {
+		l.logger.DebugContext(ctx, "guardrail batch endpoint unsupported, checking items one at a time", args...)
+		return
+	}
+	if err != nil {
+		args = append(args, "error", l.errorText(err), "error_type", errorTypeOf(err))
+		l.logger.ErrorContext(ctx, "guardrail request failed", args...)
+		return
+	}
+	if endpoint == batchEndpoint {
+		args = append(args, "items", len(resp.batchResults()))
+		l.logger.DebugContext(ctx, "guardrail request completed", args...)
+		return
+	}
+	args = append(args,
+		"risk_level", string(resp.OverallRiskLevel),
+		"action", string(resp.FinalAction()),
+		"cached", resp.Cached,
+	)
+	if resp.Degraded {
+		args = append(args, "degraded", true)
+	}
+	l.logger.DebugContext(ctx, "guardrail request completed", args...)
+}
+
+// attemptFailed Log a failed attempt, with the response body of non-2xx responses and the backoff of a retry
+func (l *clientLogger) attemptFailed(ctx context.Context, endpoint string, attempt int, resp *resty.Response, err error, retry bool, delay time.Duration) {
+	args := []interface{}{
+		"endpoint", endpoint,
+		"attempt", attempt + 1,
+		"error", l.errorText(err),
+		"error_type", errorTypeOf(err),
+	}
+	if resp != nil && resp.StatusCode() != 0 {
+		args = append(args, "status", resp.StatusCode())
+		if body := resp.Body(); len(body) > 0 {
+			args = append(args, "body", l.bodyText(body))
+		}
+	}
+	if retry {
+		args = append(args, "backoff", delay)
+		l.logger.WarnContext(ctx, "guardrail request attempt failed, retrying", args...)
+		return
+	}
+	l.logger.WarnContext(ctx, "guardrail request attempt failed", args...)
+}
+
+// parseFailed Log a response body that could not be parsed
+func (l *clientLogger) parseFailed(ctx context.Context, endpoint string, body []byte, err error) {
+	l.logger.ErrorContext(ctx, "failed to parse guardrail response",
+		"endpoint", endpoint,
+		"error", err.Error(),
+		"body", l.bodyText(body),
+	)
+}
+
+// cacheHit Log a response served from the cache
+func (l *clientLogger) cacheHit(ctx context.Context, endpoint string) {
+	l.logger.DebugContext(ctx, "guardrail response cache hit", "endpoint", endpoint)
+}
+
+// cacheStored Log a response stored in the cache
+func (l *clientLogger) cacheStored(ctx context.Context, endpoint string, ttl time.Duration) {
+	l.logger.DebugContext(ctx, "guardrail response cached", "endpoint", endpoint, "ttl", ttl)
+}
+
+// circuitRejected Log a request rejected by the open circuit breaker
+func (l *clientLogger) circuitRejected(ctx context.Context, endpoint string) {
+	l.logger.WarnContext(ctx, "guardrail request rejected by circuit breaker", "endpoint", endpoint)
+}
+
+// circuitStateChanged Log a circuit breaker state change
+func (l *clientLogger) circuitStateChanged(from, to CircuitState) {
+	l.logger.WarnContext(context.Background(), "guardrail circuit breaker state changed", "from", from.String(), "to", to.String())
+}
+
+// degraded Log a degraded response returned instead of an error
+func (l *clientLogger) degraded(ctx context.Context, endpoint string, mode FailureMode, err error) {
+	l.logger.WarnContext(ctx, "guardrail unavailable, returning degraded response",
+		"endpoint", endpoint,
+		"failure_mode", mode.String(),
+		"error", l.errorText(err),
+	)
+}
+
+// contentFields JSON fields carrying request content, masked in logged bodies unless content logging is enabled
+var contentFields = map[string]bool{
+	"content":   true,
+	"input":     true,
+	"output":    true,
+	"text":      true,
+	"prompt":    true,
+	"response":  true,
+	"image_url": true,
+}
+
+// maskContent Mask the content fields of a JSON body at any depth, replacing a body that is not JSON
+func maskContent(body []byte) []byte {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil || decoder.More() {
+		return []byte(fmt.Sprintf("%s (%d bytes)", redactedText, len(body)))
+	}
+
+	var mask func(v interface{}) interface{}
+	mask = func(v interface{}) interface{} {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for key, item := range value {
+				if contentFields[key] && item != nil {
+					value[key] = redactedText
+				} else {
+					value[key] = mask(item)
+				}
+			}
+		case []interface{}:
+			for i, item := range value {
+				value[i] = mask(item)
+			}
+		}
+		return v
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(mask(data)); err != nil {
+		return []byte(redactedText)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+// truncateLog Truncate s to at most max bytes at a character boundary, noting the original length
+func truncateLog(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(%d bytes)", s[:cut], len(s))
+}
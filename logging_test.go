@@ -0,0 +1,57 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// attemptFailure Return the body and error logged for the failed attempt of a check of content
+func attemptFailure(t *testing.T, config xiangxinai.LogConfig, fault xiangxintest.Fault, content string) (string, string) {
+	t.Helper()
+	logger := &recordingLogger{}
+	config.Logger = logger
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithLogging(config))
+	server.Fail(fault)
+
+	_, err := client.CheckPrompt(context.Background(), content)
+	require.Error(t, err)
+	records := logger.messages("guardrail request attempt failed")
+	require.Len(t, records, 1)
+	body, _ := records[0].args["body"].(string)
+	return body, records[0].args["error"].(string)
+}
+
+func TestLoggingMasksEchoedContent(t *testing.T) {
+	// The server echoes an escaped, truncated copy of the content
+	fault := xiangxintest.Fault{
+		Status: http.StatusUnprocessableEntity,
+		Body:   `{"detail":[{"loc":["body","input"],"msg":"String should have at most 8 characters","type":"string_too_long","input":"my secret été pass"}]}`,
+	}
+	body, errText := attemptFailure(t, xiangxinai.LogConfig{}, fault, "my secret été password")
+	assert.NotContains(t, body, "secret")
+	assert.Contains(t, body, "[REDACTED]")
+	assert.Contains(t, body, "string_too_long")
+	assert.NotContains(t, errText, "secret")
+	assert.Contains(t, errText, "body.input: String should have at most 8 characters")
+}
+
+func TestLoggingReplacesNonJSONBody(t *testing.T) {
+	fault := xiangxintest.Fault{Status: http.StatusBadRequest, Body: "cannot check: my secret"}
+	body, errText := attemptFailure(t, xiangxinai.LogConfig{}, fault, "my secret")
+	assert.Equal(t, "[REDACTED] (23 bytes)", body)
+	assert.NotContains(t, errText, "secret")
+}
+
+func TestLoggingKeepsContentWhenEnabled(t *testing.T) {
+	fault := xiangxintest.Fault{Status: http.StatusBadRequest, Body: `{"detail":"bad input","input":"my secret"}`}
+	body, errText := attemptFailure(t, xiangxinai.LogConfig{LogContent: true}, fault, "my secret")
+	assert.Contains(t, body, "my secret")
+	assert.Contains(t, errText, "bad input")
+}
@@ -0,0 +1,349 @@
+package xiangxinai
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskStyle How Mask replaces sensitive values
+type MaskStyle int
+
+const (
+	MaskFull    MaskStyle = iota // Replace with ***
+	MaskPartial                  // Keep the first 3 and last 4 characters, e.g. 138****5678
+	MaskHash                     // Replace with a short hash of the value, e.g. [PHONE:5f9c4ab0]
+	MaskToken                    // Replace with a typed placeholder, e.g. [PHONE_1], restorable with MaskResult.Redactions
+)
+
+// String Return the name of the mask style
+func (s MaskStyle) String() string {
+	switch s {
+	case MaskFull:
+		return "full"
+	case MaskPartial:
+		return "partial"
+	case MaskHash:
+		return "hash"
+	case MaskToken:
+		return "token"
+	default:
+		return fmt.Sprintf("MaskStyle(%d)", int(s))
+	}
+}
+
+// MaskOptions Settings of Mask
+type MaskOptions struct {
+	Style        MaskStyle         // Mask style, default MaskFull
+	Char         rune              // Mask character of MaskFull and MaskPartial, default '*'
+	HashKey      []byte            // HMAC-SHA256 key of MaskHash, nil uses plain SHA-256
+	Detectors    []Detector        // Fallback detectors, nil uses DefaultDetectors
+	Categories   map[string]string // Extra data category to detector type mapping, e.g. "员工编号": "EMPLOYEE_ID"
+	AllDetectors bool              // Run every fallback detector, not only those of the reported categories
+}
+
+// MaskResult Result of Mask
+type MaskResult struct {
+	Text       string       // Masked text
+	Entities   []DataEntity // Masked values, with offsets in the original text; Text is left empty
+	Unlocated  []string     // Reported categories of which no value was found in the text
+	Redactions *Redactions  // Placeholders of MaskToken, nil for other styles
+}
+
+// Masked Report whether any value was masked
+func (r *MaskResult) Masked() bool {
+	return len(r.Entities) > 0
+}
+
+// categoryTypes Built-in data category to detector type mapping, keyed by normalized category
+var categoryTypes = map[string]string{
+	"ID_CARD": PIIIDCard, "ID_CARD_NUMBER": PIIIDCard, "ID_NUMBER": PIIIDCard,
+	"身份证": PIIIDCard, "身份证号": PIIIDCard, "身份证号码": PIIIDCard,
+	"PHONE": PIIPhone, "PHONE_NUMBER": PIIPhone, "MOBILE": PIIPhone, "MOBILE_NUMBER": PIIPhone,
+	"手机号": PIIPhone, "手机号码": PIIPhone, "电话号码": PIIPhone,
+	"BANK_CARD": PIIBankCard, "BANK_CARD_NUMBER": PIIBankCard, "CREDIT_CARD": PIIBankCard,
+	"银行卡": PIIBankCard, "银行卡号": PIIBankCard,
+	"EMAIL": PIIEmail, "EMAIL_ADDRESS": PIIEmail,
+	"邮箱": PIIEmail, "邮箱地址": PIIEmail, "电子邮箱": PIIEmail,
+	"IP": PIIIPAddress, "IP_ADDRESS": PIIIPAddress, "IP地址": PIIIPAddress,
+	"API_KEY": PIIAPIKey, "SECRET_KEY": PIIAPIKey, "ACCESS_KEY": PIIAPIKey,
+	"密钥": PIIAPIKey, "API密钥": PIIAPIKey,
+}
+
+// Mask Mask the sensitive data reported in the data security result of resp
+//
+// Values located by the server, in DataSecurityResult.Entities, are masked at
+// their offsets; pass the same text that was checked. Reported categories
+// without located values fall back to the local detectors of their type, see
+// MaskOptions.Categories for the mapping. Offsets are ignored for responses of
+// clients using WithRedaction, as they refer to the redacted text. A response
+// without sensitive data categories leaves the text unchanged.
+//
+// Example:
+//
+//	result, err := client.CheckPrompt(ctx, prompt)
+//	if err == nil && result.RiskFor(xiangxinai.DimensionData) != xiangxinai.NoRisk {
+//		masked, err := xiangxinai.Mask(prompt, result, xiangxinai.MaskOptions{Style: xiangxinai.MaskPartial})
+//		if err == nil && len(masked.Unlocated) == 0 {
+//			prompt = masked.Text // "My phone is 138****5678"
+//		}
+//	}
+func Mask(text string, resp *GuardrailResponse, opts MaskOptions) (*MaskResult, error) {
+	if opts.Style < MaskFull || opts.Style > MaskToken {
+		return nil, NewValidationError(fmt.Sprintf("invalid mask style %d", int(opts.Style)))
+	}
+	if opts.Char == 0 {
+		opts.Char = '*'
+	}
+	detectors := opts.Detectors
+	if detectors == nil {
+		detectors = DefaultDetectors()
+	}
+	for _, detector := range detectors {
+		if detector.Pattern == nil || !detectorTypePattern.MatchString(detector.Type) {
+			return nil, NewValidationError(fmt.Sprintf("invalid detector %q: a type of upper case letters, digits and underscores and a pattern are required", detector.Type))
+		}
+	}
+
+	result := &MaskResult{Text: text}
+	var data *DataSecurityResult
+	if resp != nil && resp.Result != nil {
+		data = resp.Result.Data
+	}
+	if data == nil || (len(data.Categories) == 0 && len(data.Entities) == 0) {
+		return result, nil
+	}
+
+	// Values located by the server
+	var spans []redactionMatch
+	located := make(map[string]bool)
+	if resp.Redactions.Len() == 0 {
+		for _, entity := range data.Entities {
+			matches, err := entitySpans(text, entity)
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range matches {
+				match.typ = opts.maskType(entity.Category)
+				spans = append(spans, match)
+			}
+			located[entity.Category] = true
+		}
+	}
+	spans = resolveMatches(spans)
+
+	// Local detectors for the remaining categories
+	var pending []string
+	types := make(map[string]string) // Detector type to reported category
+	for _, category := range data.Categories {
+		if located[category] {
+			continue
+		}
+		pending = append(pending, category)
+		if typ := opts.categoryType(category); typ != "" && types[typ] == "" {
+			types[typ] = category
+		}
+	}
+	var matches []redactionMatch
+	if len(pending) > 0 {
+		var selected []Detector
+		for _, detector := range detectors {
+			if _, ok := types[detector.Type]; ok || opts.AllDetectors {
+				selected = append(selected, detector)
+			}
+		}
+		for _, match := range resolveMatches(detectMatches(text, selected)) {
+			if !overlapsAny(match, spans) {
+				match.category = types[match.typ]
+				matches = append(matches, match)
+			}
+		}
+	}
+	found := make(map[string]bool)
+	for _, match := range matches {
+		found[match.typ] = true
+	}
+	for _, category := range pending {
+		if typ := opts.categoryType(category); typ == "" || !found[typ] {
+			result.Unlocated = append(result.Unlocated, category)
+		}
+	}
+
+	matches = resolveMatches(append(spans, matches...))
+	if len(matches) == 0 {
+		return result, nil
+	}
+	if opts.Style == MaskToken {
+		result.Redactions = newRedactions()
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		value := text[match.start:match.end]
+		builder.WriteString(text[last:match.start])
+		builder.WriteString(opts.mask(match.typ, value, result.Redactions))
+		last = match.end
+
+		category := match.category
+		if category == "" {
+			category = match.typ
+		}
+		start := utf8.RuneCountInString(text[:match.start])
+		result.Entities = append(result.Entities, DataEntity{
+			Category: category,
+			Start:    start,
+			End:      start + utf8.RuneCountInString(value),
+		})
+	}
+	builder.WriteString(text[last:])
+	result.Text = builder.String()
+	return result, nil
+}
+
+// entitySpans Return the byte spans of a server entity in text
+//
+// If the offsets do not match the entity text, for instance because the server
+// normalized the text, the occurrences of the entity text are used instead.
+func entitySpans(text string, entity DataEntity) ([]redactionMatch, error) {
+	start, end := runeOffset(text, entity.Start), runeOffset(text, entity.End)
+	valid := entity.Start >= 0 && entity.End > entity.Start && start >= 0 && end >= 0
+	if valid && (entity.Text == "" || text[start:end] == entity.Text) {
+		return []redactionMatch{{start: start, end: end, category: entity.Category}}, nil
+	}
+	if entity.Text == "" {
+		return nil, NewValidationError(fmt.Sprintf("data entity %s offsets [%d, %d) out of range of the text", entity.Category, entity.Start, entity.End))
+	}
+
+	var spans []redactionMatch
+	for offset := 0; ; {
+		index := strings.Index(text[offset:], entity.Text)
+		if index < 0 {
+			break
+		}
+		start := offset + index
+		spans = append(spans, redactionMatch{start: start, end: start + len(entity.Text), category: entity.Category})
+		offset = start + len(entity.Text)
+	}
+	if len(spans) == 0 {
+		return nil, NewValidationError(fmt.Sprintf("data entity %s not found in the text", entity.Category))
+	}
+	return spans, nil
+}
+
+// runeOffset Return the byte offset of the n-th character of text, -1 if out of range
+func runeOffset(text string, n int) int {
+	if n < 0 {
+		return -1
+	}
+	for offset := range text {
+		if n == 0 {
+			return offset
+		}
+		n--
+	}
+	if n == 0 {
+		return len(text)
+	}
+	return -1
+}
+
+// overlapsAny Report whether match overlaps one of spans
+func overlapsAny(match redactionMatch, spans []redactionMatch) bool {
+	for _, span := range spans {
+		if match.start < span.end && span.start < match.end {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCategory Normalize a data category for lookup, e.g. "Phone Number" and "PHONE_NUMBER_SYS" to PHONE_NUMBER
+func normalizeCategory(category string) string {
+	category = strings.ToUpper(strings.TrimSpace(category))
+	category = strings.NewReplacer(" ", "_", "-", "_").Replace(category)
+	return strings.TrimSuffix(category, "_SYS")
+}
+
+// categoryType Return the detector type of a data category, empty if unknown
+func (o MaskOptions) categoryType(category string) string {
+	if typ, ok := o.Categories[category]; ok {
+		return typ
+	}
+	normalized := normalizeCategory(category)
+	if typ, ok := o.Categories[normalized]; ok {
+		return typ
+	}
+	if typ, ok := categoryTypes[normalized]; ok {
+		return typ
+	}
+	for _, detector := range o.Detectors {
+		if detector.Type == normalized {
+			return normalized
+		}
+	}
+	return ""
+}
+
+// maskType Return the type used in the hash and token of a server entity
+func (o MaskOptions) maskType(category string) string {
+	if typ := o.categoryType(category); typ != "" {
+		return typ
+	}
+	if normalized := normalizeCategory(category); detectorTypePattern.MatchString(normalized) {
+		return normalized
+	}
+	return "DATA"
+}
+
+// mask Return the replacement of a sensitive value
+func (o MaskOptions) mask(typ, value string, redactions *Redactions) string {
+	switch o.Style {
+	case MaskPartial:
+		return o.maskPartial(typ, value)
+	case MaskHash:
+		var sum []byte
+		if o.HashKey != nil {
+			mac := hmac.New(sha256.New, o.HashKey)
+			mac.Write([]byte(value))
+			sum = mac.Sum(nil)
+		} else {
+			hash := sha256.Sum256([]byte(value))
+			sum = hash[:]
+		}
+		return "[" + typ + ":" + hex.EncodeToString(sum[:4]) + "]"
+	case MaskToken:
+		return redactions.placeholder(typ, value)
+	default:
+		return strings.Repeat(string(o.Char), 3)
+	}
+}
+
+// maskPartial Mask the middle of a value, keeping the first 3 and last 4 characters of long values
+//
+// Emails keep the first character of the local part and the domain, e.g. z***@example.com.
+func (o MaskOptions) maskPartial(typ, value string) string {
+	if typ == PIIEmail {
+		if at := strings.LastIndex(value, "@"); at > 0 {
+			first, size := utf8.DecodeRuneInString(value)
+			if size < at {
+				return string(first) + strings.Repeat(string(o.Char), 3) + value[at:]
+			}
+		}
+	}
+
+	runes := []rune(value)
+	prefix, suffix := 3, 4
+	if len(runes) < 11 {
+		prefix, suffix = len(runes)/4, len(runes)/4
+	}
+	masked := make([]rune, 0, len(runes))
+	masked = append(masked, runes[:prefix]...)
+	for range runes[prefix : len(runes)-suffix] {
+		masked = append(masked, o.Char)
+	}
+	return string(append(masked, runes[len(runes)-suffix:]...))
+}
@@ -0,0 +1,54 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestMaskServerEntities(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	const prompt = "My phone is 13812345678, my id is E-1234"
+	server.OnContent(`13812345678`, xiangxintest.Verdict{
+		Dimension:  xiangxinai.DimensionData,
+		Categories: []string{"PHONE_NUMBER", "EMPLOYEE_ID"},
+		RiskLevel:  xiangxinai.MediumRisk,
+		Entities: []xiangxinai.DataEntity{
+			{Category: "PHONE_NUMBER", Start: 12, End: 23, Text: "13812345678"},
+		},
+	})
+
+	result, err := client.CheckPrompt(context.Background(), prompt)
+	require.NoError(t, err)
+
+	masked, err := xiangxinai.Mask(prompt, result, xiangxinai.MaskOptions{Style: xiangxinai.MaskPartial})
+	require.NoError(t, err)
+	assert.Equal(t, "My phone is 138****5678, my id is E-1234", masked.Text)
+	require.Len(t, masked.Entities, 1)
+	assert.Equal(t, 12, masked.Entities[0].Start)
+	assert.Equal(t, []string{"EMPLOYEE_ID"}, masked.Unlocated)
+
+	masked, err = xiangxinai.Mask(prompt, result, xiangxinai.MaskOptions{Style: xiangxinai.MaskToken})
+	require.NoError(t, err)
+	assert.Equal(t, "My phone is [PHONE_1], my id is E-1234", masked.Text)
+	assert.Equal(t, prompt, masked.Redactions.Restore(masked.Text))
+}
+
+func TestMaskFallsBackToDetectors(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	const prompt = "Mail zhang@example.com"
+	server.OnCategory(xiangxinai.DimensionData, "EMAIL", `@`)
+
+	result, err := client.CheckPrompt(context.Background(), prompt)
+	require.NoError(t, err)
+
+	masked, err := xiangxinai.Mask(prompt, result, xiangxinai.MaskOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Mail ***", masked.Text)
+	assert.Empty(t, masked.Unlocated)
+}
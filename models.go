@@ -0,0 +1,289 @@
+package xiangxinai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultImageModel Image model used when the model list does not name one
+	DefaultImageModel = "Xiangxin-Guardrails-VL"
+
+	// modelsCacheTTL Time the model list is kept for model discovery
+	modelsCacheTTL = 10 * time.Minute
+)
+
+// Modality Input modality of a model: text, image
+type Modality string
+
+// Modalities
+const (
+	ModalityText  Modality = "text"
+	ModalityImage Modality = "image"
+)
+
+// HealthStatus Typed health check response, returned by Client.Health
+type HealthStatus struct {
+	Status     string                     `json:"status"`               // Overall status, e.g. healthy
+	Version    string                     `json:"version,omitempty"`    // API version
+	Components map[string]ComponentStatus `json:"components,omitempty"` // Status of individual components, e.g. the model service
+	Latency    time.Duration              `json:"-"`                    // Round-trip time of the health check, measured by the client
+	Raw        map[string]interface{}     `json:"-"`                    // Full response, including fields not mapped above
+}
+
+// ComponentStatus Health of an API component
+type ComponentStatus struct {
+	Status    string  `json:"status"`               // Component status, e.g. healthy
+	Message   string  `json:"message,omitempty"`    // Optional detail
+	LatencyMS float64 `json:"latency_ms,omitempty"` // Component latency reported by the server
+}
+
+// IsHealthy Check if the overall status is healthy
+func (s *HealthStatus) IsHealthy() bool {
+	return isHealthyStatus(s.Status)
+}
+
+// Unhealthy Return the names of the components that are not healthy
+func (s *HealthStatus) Unhealthy() []string {
+	var names []string
+	for name, component := range s.Components {
+		if !isHealthyStatus(component.Status) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isHealthyStatus Check if a status string means healthy
+func isHealthyStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "healthy", "ok", "up", "pass":
+		return true
+	default:
+		return false
+	}
+}
+
+// ModelInfo Guardrail model, an entry of Client.ListModels
+type ModelInfo struct {
+	ID               string      `json:"id"`                           // Model name, used as the model of check requests
+	Object           string      `json:"object,omitempty"`             // Object type, "model"
+	OwnedBy          string      `json:"owned_by,omitempty"`           // Model owner
+	Modalities       []Modality  `json:"modalities,omitempty"`         // Supported input modalities
+	MaxContextLength int         `json:"max_context_length,omitempty"` // Maximum context length in tokens, zero if unknown
+	Dimensions       []Dimension `json:"dimensions,omitempty"`         // Supported detection dimensions
+}
+
+// SupportsImages Check if the model accepts image input
+//
+// Models without reported modalities are assumed to accept images if their
+// name has a "-VL" (vision-language) suffix, as the current models do.
+func (m *ModelInfo) SupportsImages() bool {
+	if len(m.Modalities) == 0 {
+		return strings.HasSuffix(strings.ToUpper(m.ID), "-VL")
+	}
+	for _, modality := range m.Modalities {
+		if modality == ModalityImage {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsDimension Check if the model detects the dimension, true if the model does not report dimensions
+func (m *ModelInfo) SupportsDimension(dimension Dimension) bool {
+	if len(m.Dimensions) == 0 {
+		return true
+	}
+	for _, d := range m.Dimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelList Available models, returned by Client.ListModels
+type ModelList []*ModelInfo
+
+// Find Return the model with the given name
+func (l ModelList) Find(model string) (*ModelInfo, bool) {
+	for _, info := range l {
+		if info.ID == model {
+			return info, true
+		}
+	}
+	return nil, false
+}
+
+// SupportsImages Check if the named model accepts image input, false if it is not listed
+func (l ModelList) SupportsImages(model string) bool {
+	info, ok := l.Find(model)
+	return ok && info.SupportsImages()
+}
+
+// ImageModel Return the first model accepting image input
+func (l ModelList) ImageModel() (string, bool) {
+	for _, info := range l {
+		if info.SupportsImages() {
+			return info.ID, true
+		}
+	}
+	return "", false
+}
+
+// Health Check API service health status, returning the typed status
+//
+// Example:
+//
+//	status, err := client.Health(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if !status.IsHealthy() {
+//		log.Printf("guardrail unhealthy: %s (components: %v)", status.Status, status.Unhealthy())
+//	}
+func (c *Client) Health(ctx context.Context) (*HealthStatus, error) {
+	start := time.Now()
+	resp, err := c.doWithRetry(ctx, http.MethodGet, "/guardrails/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	latency := time.Since(start)
+
+	var status HealthStatus
+	if err := json.Unmarshal(resp.Body(), &status); err != nil {
+		return nil, NewXiangxinAIError("failed to parse response", err)
+	}
+	if err := json.Unmarshal(resp.Body(), &status.Raw); err != nil {
+		return nil, NewXiangxinAIError("failed to parse response", err)
+	}
+	status.Latency = latency
+	return &status, nil
+}
+
+// ListModels Get available model list, returning typed model information
+//
+// Example:
+//
+//	models, err := client.ListModels(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, model := range models {
+//		fmt.Println(model.ID, model.SupportsImages())
+//	}
+func (c *Client) ListModels(ctx context.Context) (ModelList, error) {
+	resp, err := c.doWithRetry(ctx, http.MethodGet, "/guardrails/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	models, err := parseModels(resp.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	c.modelsMu.Lock()
+	c.models = models
+	c.modelsErr = nil
+	c.modelsFetched = time.Now()
+	c.modelsMu.Unlock()
+	return models, nil
+}
+
+// parseModels Parse a /guardrails/models response
+func parseModels(body []byte) (ModelList, error) {
+	var list struct {
+		Data ModelList `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, NewXiangxinAIError("failed to parse response", err)
+	}
+	return list.Data, nil
+}
+
+// SupportsImages Check if the model accepts image input, using the cached model list
+func (c *Client) SupportsImages(ctx context.Context, model string) (bool, error) {
+	models, err := c.cachedModels(ctx)
+	if err != nil {
+		return false, err
+	}
+	return models.SupportsImages(model), nil
+}
+
+// ImageModel Return the model used by CheckPromptImage and CheckPromptImages
+//
+// The model is discovered from the cached model list; DefaultImageModel is
+// returned if the list cannot be fetched or has no image model.
+func (c *Client) ImageModel(ctx context.Context) string {
+	models, err := c.cachedModels(ctx)
+	if err != nil {
+		return DefaultImageModel
+	}
+	if model, ok := models.ImageModel(); ok {
+		return model
+	}
+	return DefaultImageModel
+}
+
+// cachedModels Return the model list, fetching it if the cached list is missing or expired
+//
+// Concurrent callers share a single fetch, sent through the circuit breaker
+// and the retry policy. An expired list is returned at once while it is
+// refreshed in the background, and kept if the refresh fails. Failed first
+// fetches are cached as well, so an unavailable model list does not delay
+// every call.
+func (c *Client) cachedModels(ctx context.Context) (ModelList, error) {
+	c.modelsMu.Lock()
+	fetched := !c.modelsFetched.IsZero()
+	if fetched && (c.modelsErr == nil || time.Since(c.modelsFetched) < modelsCacheTTL) {
+		models, err := c.models, c.modelsErr
+		if time.Since(c.modelsFetched) >= modelsCacheTTL && c.modelsRefresh == nil {
+			c.modelsRefresh = make(chan struct{})
+			go c.refreshModels(detachedContext{parent: ctx})
+		}
+		c.modelsMu.Unlock()
+		return models, err
+	}
+	refresh := c.modelsRefresh
+	if refresh == nil {
+		refresh = make(chan struct{})
+		c.modelsRefresh = refresh
+		go c.refreshModels(detachedContext{parent: ctx})
+	}
+	c.modelsMu.Unlock()
+
+	select {
+	case <-refresh:
+		c.modelsMu.Lock()
+		defer c.modelsMu.Unlock()
+		return c.models, c.modelsErr
+	case <-ctx.Done():
+		return nil, NewNetworkError("request failed", ctx.Err())
+	}
+}
+
+// refreshModels Fetch the model list for cachedModels, keeping the cached list on failure
+func (c *Client) refreshModels(ctx context.Context) {
+	body, err := c.send(ctx, http.MethodGet, "/guardrails/models", nil)
+	var models ModelList
+	if err == nil {
+		models, err = parseModels(body)
+	}
+
+	c.modelsMu.Lock()
+	defer c.modelsMu.Unlock()
+	if err == nil {
+		c.models = models
+		c.modelsErr = nil
+	} else if c.modelsFetched.IsZero() || c.modelsErr != nil {
+		c.models = nil
+		c.modelsErr = err
+	}
+	c.modelsFetched = time.Now()
+	close(c.modelsRefresh)
+	c.modelsRefresh = nil
+}
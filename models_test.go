@@ -0,0 +1,97 @@
+package xiangxinai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modelsServer Return a client of a server answering /guardrails/models, failing while fail is set
+func modelsServer(t *testing.T, requests, fail *int32, latency time.Duration, opts ...Option) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		time.Sleep(latency)
+		if atomic.LoadInt32(fail) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","data":[{"id":"Guard-VL","modalities":["text","image"]}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("test-key", append([]Option{WithBaseURL(server.URL), WithMaxRetries(0)}, opts...)...)
+	require.NoError(t, err)
+	return client
+}
+
+func TestCachedModelsSharesFetch(t *testing.T) {
+	var requests, fail int32
+	client := modelsServer(t, &requests, &fail, 100*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, "Guard-VL", client.ImageModel(context.Background()))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestCachedModelsKeepsStaleList(t *testing.T) {
+	var requests, fail int32
+	client := modelsServer(t, &requests, &fail, 0)
+	ctx := context.Background()
+	require.Equal(t, "Guard-VL", client.ImageModel(ctx))
+
+	expire := func() {
+		client.modelsMu.Lock()
+		client.modelsFetched = time.Now().Add(-modelsCacheTTL)
+		client.modelsMu.Unlock()
+	}
+	waitRefresh := func() {
+		client.modelsMu.Lock()
+		refresh := client.modelsRefresh
+		client.modelsMu.Unlock()
+		if refresh != nil {
+			<-refresh
+		}
+	}
+
+	// The expired list is served while a failed refresh runs in the background
+	atomic.StoreInt32(&fail, 1)
+	expire()
+	assert.Equal(t, "Guard-VL", client.ImageModel(ctx))
+	waitRefresh()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	supports, err := client.SupportsImages(ctx, "Guard-VL")
+	require.NoError(t, err)
+	assert.True(t, supports)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// The next expiry refreshes the list again
+	atomic.StoreInt32(&fail, 0)
+	expire()
+	assert.Equal(t, "Guard-VL", client.ImageModel(ctx))
+	waitRefresh()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestCachedModelsUsesCircuitBreaker(t *testing.T) {
+	var requests int32
+	fail := int32(1)
+	client := modelsServer(t, &requests, &fail, 0, WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute}))
+
+	assert.Equal(t, DefaultImageModel, client.ImageModel(context.Background()))
+	assert.Equal(t, CircuitOpen, client.CircuitState())
+}
@@ -0,0 +1,525 @@
+package xiangxinai
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Option Client option, used with NewClient
+//
+// Example usage:
+//
+//	client, err := xiangxinai.NewClient("your-api-key",
+//		xiangxinai.WithBaseURL("https://guardrails.example.com/v1"),
+//		xiangxinai.WithTimeout(10*time.Second),
+//		xiangxinai.WithRootCAFile("/etc/ssl/private-ca.pem"),
+//	)
+type Option func(*clientOptions) error
+
+// clientOptions Collected client options
+type clientOptions struct {
+	baseURL           string
+	timeout           time.Duration
+	maxRetries        int
+	retryPolicy       RetryPolicy
+	breakerConfig     *CircuitBreakerConfig
+	failureMode       FailureMode
+	failClosedAnswer  string
+	policy            *Policy
+	cacheConfig       *CacheConfig
+	coalesce          bool
+	rateLimit         *RateLimitConfig
+	batchConfig       BatchConfig
+	imageConfig       ImageConfig
+	telemetry         *TelemetryConfig
+	logConfig         *LogConfig
+	interceptors      []Interceptor
+	batchInterceptors []BatchInterceptor
+	redaction         *RedactionConfig
+	httpClient        *http.Client
+	transport         http.RoundTripper
+	proxyURL          *url.URL
+	tlsConfig         *tls.Config
+	headers           map[string]string
+	userAgentSuffix   string
+}
+
+// defaultClientOptions Create default client options
+func defaultClientOptions() *clientOptions {
+	return &clientOptions{
+		baseURL:          DefaultBaseURL,
+		maxRetries:       DefaultMaxRetries,
+		failClosedAnswer: DefaultFailClosedAnswer,
+		batchConfig:      BatchConfig{ChunkSize: DefaultBatchChunkSize, Concurrency: DefaultBatchConcurrency},
+		imageConfig:      ImageConfig{MaxBytes: DefaultImageMaxBytes, MaxSourceBytes: DefaultImageMaxSourceBytes, JPEGQuality: DefaultImageJPEGQuality, MaxPixels: DefaultImageMaxPixels},
+		headers:          make(map[string]string),
+	}
+}
+
+// WithBaseURL Set API base URL, default DefaultBaseURL
+func WithBaseURL(baseURL string) Option {
+	return func(o *clientOptions) error {
+		if baseURL != "" {
+			o.baseURL = baseURL
+		}
+		return nil
+	}
+}
+
+// WithTimeout Set request timeout, default DefaultTimeout seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) error {
+		if timeout < 0 {
+			return NewValidationError("timeout cannot be negative")
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithMaxRetries Set maximum retry count, negative values use DefaultMaxRetries
+func WithMaxRetries(maxRetries int) Option {
+	return func(o *clientOptions) error {
+		if maxRetries < 0 {
+			maxRetries = DefaultMaxRetries
+		}
+		o.maxRetries = maxRetries
+		return nil
+	}
+}
+
+// WithRetryPolicy Use a custom retry policy, overriding WithMaxRetries
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) error {
+		if policy == nil {
+			return NewValidationError("retry policy cannot be nil")
+		}
+		o.retryPolicy = policy
+		return nil
+	}
+}
+
+// WithCircuitBreaker Enable the client-side circuit breaker around Check* requests
+//
+// Zero fields of config use the defaults, e.g. WithCircuitBreaker(CircuitBreakerConfig{}).
+func WithCircuitBreaker(config CircuitBreakerConfig) Option {
+	return func(o *clientOptions) error {
+		o.breakerConfig = &config
+		return nil
+	}
+}
+
+// WithFailureMode Set the behavior of Check* calls when the service is unavailable
+//
+// Applies to network errors, 5xx responses and open circuit errors. Degraded
+// responses have Degraded set to true.
+func WithFailureMode(mode FailureMode) Option {
+	return func(o *clientOptions) error {
+		if mode < FailureModeError || mode > FailClosed {
+			return NewValidationError("invalid failure mode")
+		}
+		o.failureMode = mode
+		return nil
+	}
+}
+
+// WithFailClosedAnswer Set the suggested answer of FailClosed degraded responses
+func WithFailClosedAnswer(answer string) Option {
+	return func(o *clientOptions) error {
+		o.failClosedAnswer = answer
+		return nil
+	}
+}
+
+// WithPolicy Attach a local policy; every Check* response then carries its Decision
+//
+// The client validates and keeps a copy of policy, so later changes to policy
+// do not affect the client.
+func WithPolicy(policy *Policy) Option {
+	return func(o *clientOptions) error {
+		if policy == nil {
+			return NewValidationError("policy cannot be nil")
+		}
+		policy = policy.Clone()
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+		o.policy = policy
+		return nil
+	}
+}
+
+// WithCache Enable the response cache for Check* requests
+//
+// Zero fields of config use the defaults, e.g. WithCache(CacheConfig{}).
+func WithCache(config CacheConfig) Option {
+	return func(o *clientOptions) error {
+		o.cacheConfig = &config
+		return nil
+	}
+}
+
+// WithRateLimit Enable the client-side rate limiter, see RateLimitConfig
+func WithRateLimit(config RateLimitConfig) Option {
+	return func(o *clientOptions) error {
+		if config.QPS <= 0 || math.IsInf(config.QPS, 0) || math.IsNaN(config.QPS) {
+			return NewValidationError("rate limit QPS must be positive")
+		}
+		if config.Burst < 0 {
+			return NewValidationError("rate limit burst cannot be negative")
+		}
+		o.rateLimit = &config
+		return nil
+	}
+}
+
+// WithBatch Configure CheckBatch, zero fields use the defaults
+func WithBatch(config BatchConfig) Option {
+	return func(o *clientOptions) error {
+		if config.ChunkSize <= 0 {
+			config.ChunkSize = DefaultBatchChunkSize
+		}
+		if config.Concurrency <= 0 {
+			config.Concurrency = DefaultBatchConcurrency
+		}
+		o.batchConfig = config
+		return nil
+	}
+}
+
+// WithTelemetry Enable OpenTelemetry spans and metrics of Check* calls, see TelemetryConfig
+//
+// Zero fields of config use the global providers, e.g. WithTelemetry(TelemetryConfig{}).
+func WithTelemetry(config TelemetryConfig) Option {
+	return func(o *clientOptions) error {
+		o.telemetry = &config
+		return nil
+	}
+}
+
+// WithLogger Log requests, retries and client events to logger, e.g. a *slog.Logger
+//
+// Request content and the API key are not logged, see LogConfig.
+func WithLogger(logger Logger) Option {
+	return WithLogging(LogConfig{Logger: logger})
+}
+
+// WithLogging Log requests, retries and client events, see LogConfig
+func WithLogging(config LogConfig) Option {
+	return func(o *clientOptions) error {
+		if config.Logger == nil {
+			return NewValidationError("logger cannot be nil")
+		}
+		o.logConfig = &config
+		return nil
+	}
+}
+
+// WithInterceptors Add interceptors wrapping every Check* request, see Interceptor
+//
+// Interceptors run in the order given; repeated options append to the chain.
+// Batch requests of CheckBatch go to WithBatchInterceptors instead.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(o *clientOptions) error {
+		for _, interceptor := range interceptors {
+			if interceptor == nil {
+				return NewValidationError("interceptor cannot be nil")
+			}
+		}
+		o.interceptors = append(o.interceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithBatchInterceptors Add interceptors wrapping the batch requests of CheckBatch, see BatchInterceptor
+//
+// Interceptors run in the order given; repeated options append to the chain.
+func WithBatchInterceptors(interceptors ...BatchInterceptor) Option {
+	return func(o *clientOptions) error {
+		for _, interceptor := range interceptors {
+			if interceptor == nil {
+				return NewValidationError("batch interceptor cannot be nil")
+			}
+		}
+		o.batchInterceptors = append(o.batchInterceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithRedaction Replace sensitive values with typed placeholders before content is sent, see Redactor
+//
+// The placeholder mapping of each check is returned in GuardrailResponse.Redactions.
+// Zero fields of config use the defaults, e.g. WithRedaction(RedactionConfig{}).
+func WithRedaction(config RedactionConfig) Option {
+	return func(o *clientOptions) error {
+		for _, detector := range config.Detectors {
+			if detector.Pattern == nil || !detectorTypePattern.MatchString(detector.Type) {
+				return NewValidationError(fmt.Sprintf("invalid redaction detector %q", detector.Type))
+			}
+		}
+		o.redaction = &config
+		return nil
+	}
+}
+
+// WithImageConfig Configure image uploads of image checks, zero fields use the defaults
+func WithImageConfig(config ImageConfig) Option {
+	return func(o *clientOptions) error {
+		if config.MaxDimension < 0 {
+			return NewValidationError("image max dimension cannot be negative")
+		}
+		if config.MaxPixels < 0 {
+			return NewValidationError("image max pixels cannot be negative")
+		}
+		if config.JPEGQuality < 0 || config.JPEGQuality > 100 {
+			return NewValidationError("image JPEG quality must be between 1 and 100")
+		}
+		if config.MaxBytes <= 0 {
+			config.MaxBytes = DefaultImageMaxBytes
+		}
+		if config.MaxSourceBytes <= 0 {
+			config.MaxSourceBytes = DefaultImageMaxSourceBytes
+		}
+		if config.MaxSourceBytes < config.MaxBytes {
+			config.MaxSourceBytes = config.MaxBytes
+		}
+		if config.JPEGQuality == 0 {
+			config.JPEGQuality = DefaultImageJPEGQuality
+		}
+		if config.MaxPixels == 0 {
+			config.MaxPixels = DefaultImageMaxPixels
+		}
+		o.imageConfig = config
+		return nil
+	}
+}
+
+// WithRequestCoalescing Deduplicate concurrent identical Check* requests
+//
+// Concurrent calls with the same endpoint, body and user ID share a single API
+// request. Each caller still honors its own context; the shared request is
+// cancelled once every caller has given up. See Client.CoalesceStats.
+func WithRequestCoalescing() Option {
+	return func(o *clientOptions) error {
+		o.coalesce = true
+		return nil
+	}
+}
+
+// WithHTTPClient Use a custom *http.Client for all requests
+//
+// The client is copied, so the caller's instance is never modified.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *clientOptions) error {
+		if httpClient == nil {
+			return NewValidationError("http client cannot be nil")
+		}
+		o.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithTransport Use a custom http.RoundTripper for all requests
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *clientOptions) error {
+		if transport == nil {
+			return NewValidationError("transport cannot be nil")
+		}
+		o.transport = transport
+		return nil
+	}
+}
+
+// WithProxy Send requests through the given proxy URL, e.g. "http://proxy.internal:3128"
+func WithProxy(proxyURL string) Option {
+	return func(o *clientOptions) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return NewValidationError(fmt.Sprintf("invalid proxy URL: %s", proxyURL))
+		}
+		o.proxyURL = u
+		return nil
+	}
+}
+
+// WithTLSConfig Use a custom TLS configuration
+//
+// Root CAs and client certificates of WithRootCAs, WithRootCAFile,
+// WithClientCertificate and WithClientCertificateFile are kept in any option
+// order; setting root CAs both here and in another option is an error.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *clientOptions) error {
+		if config == nil {
+			return NewValidationError("TLS config cannot be nil")
+		}
+		merged := config.Clone()
+		if o.tlsConfig != nil {
+			if merged.RootCAs != nil && o.tlsConfig.RootCAs != nil {
+				return NewValidationError("root CAs are set by both the TLS config and another option")
+			}
+			if merged.RootCAs == nil {
+				merged.RootCAs = o.tlsConfig.RootCAs
+			}
+			merged.Certificates = append(o.tlsConfig.Certificates, merged.Certificates...)
+		}
+		o.tlsConfig = merged
+		return nil
+	}
+}
+
+// WithRootCAs Trust the given certificate pool, e.g. for a private deployment with a custom CA
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(o *clientOptions) error {
+		if pool == nil {
+			return NewValidationError("root CA pool cannot be nil")
+		}
+		return o.setRootCAs(pool)
+	}
+}
+
+// WithRootCAFile Trust the PEM encoded CA certificates in the given file
+func WithRootCAFile(caFile string) Option {
+	return func(o *clientOptions) error {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return NewXiangxinAIError(fmt.Sprintf("failed to read CA file %s", caFile), err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return NewValidationError(fmt.Sprintf("no valid PEM certificates found in %s", caFile))
+		}
+		return o.setRootCAs(pool)
+	}
+}
+
+// WithClientCertificate Present the given client certificate (mTLS)
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(o *clientOptions) error {
+		o.tls().Certificates = append(o.tls().Certificates, cert)
+		return nil
+	}
+}
+
+// WithClientCertificateFile Present the client certificate loaded from PEM files (mTLS)
+func WithClientCertificateFile(certFile, keyFile string) Option {
+	return func(o *clientOptions) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return NewXiangxinAIError("failed to load client certificate", err)
+		}
+		o.tls().Certificates = append(o.tls().Certificates, cert)
+		return nil
+	}
+}
+
+// WithHeader Add a default header sent with every request
+func WithHeader(key, value string) Option {
+	return func(o *clientOptions) error {
+		if strings.EqualFold(key, "Authorization") {
+			return NewValidationError("Authorization header is set from the API key")
+		}
+		o.headers[key] = value
+		return nil
+	}
+}
+
+// WithHeaders Add default headers sent with every request
+func WithHeaders(headers map[string]string) Option {
+	return func(o *clientOptions) error {
+		for key, value := range headers {
+			if err := WithHeader(key, value)(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithUserAgentSuffix Append a suffix to the User-Agent header, e.g. "my-service/1.0"
+func WithUserAgentSuffix(suffix string) Option {
+	return func(o *clientOptions) error {
+		o.userAgentSuffix = strings.TrimSpace(suffix)
+		return nil
+	}
+}
+
+// tls Return the TLS configuration, creating it if needed
+func (o *clientOptions) tls() *tls.Config {
+	if o.tlsConfig == nil {
+		o.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return o.tlsConfig
+}
+
+// setRootCAs Set the trusted root CAs, failing if another option already set them
+func (o *clientOptions) setRootCAs(pool *x509.CertPool) error {
+	if o.tls().RootCAs != nil {
+		return NewValidationError("root CAs are already set by another option")
+	}
+	o.tlsConfig.RootCAs = pool
+	return nil
+}
+
+// buildHTTPClient Build the underlying *http.Client from the options
+func (o *clientOptions) buildHTTPClient() (*http.Client, error) {
+	httpClient := &http.Client{}
+	if o.httpClient != nil {
+		copied := *o.httpClient
+		httpClient = &copied
+	}
+
+	if o.transport != nil {
+		httpClient.Transport = o.transport
+	}
+
+	if o.proxyURL != nil || o.tlsConfig != nil {
+		var transport *http.Transport
+		switch t := httpClient.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			transport = t.Clone()
+		default:
+			return nil, NewValidationError("proxy and TLS options require the transport to be an *http.Transport")
+		}
+		if o.proxyURL != nil {
+			transport.Proxy = http.ProxyURL(o.proxyURL)
+		}
+		if o.tlsConfig != nil {
+			transport.TLSClientConfig = o.tlsConfig
+		}
+		httpClient.Transport = transport
+	}
+
+	if o.timeout > 0 {
+		httpClient.Timeout = o.timeout
+	} else if httpClient.Timeout <= 0 {
+		httpClient.Timeout = time.Duration(DefaultTimeout) * time.Second
+	}
+
+	return httpClient, nil
+}
+
+// userAgent Return the User-Agent header value
+func (o *clientOptions) userAgent() string {
+	if o.userAgentSuffix == "" {
+		return UserAgent
+	}
+	return UserAgent + " " + o.userAgentSuffix
+}
+
+// Options Convert the configuration to client options, used by NewClientWithConfig
+func (c *ClientConfig) Options() []Option {
+	opts := []Option{WithBaseURL(c.BaseURL), WithMaxRetries(c.MaxRetries)}
+	if c.Timeout > 0 {
+		opts = append(opts, WithTimeout(time.Duration(c.Timeout)*time.Second))
+	}
+	return opts
+}
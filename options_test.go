@@ -0,0 +1,98 @@
+package xiangxinai
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// applyOptions Apply opts to the default options
+func applyOptions(t *testing.T, opts ...Option) (*clientOptions, error) {
+	t.Helper()
+	options := defaultClientOptions()
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+func TestTransportOptionsCloneTransport(t *testing.T) {
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	pool := x509.NewCertPool()
+
+	options, err := applyOptions(t, WithProxy("http://proxy.internal:3128"), WithRootCAs(pool))
+	require.NoError(t, err)
+	httpClient, err := options.buildHTTPClient()
+	require.NoError(t, err)
+
+	transport, ok := httpClient.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.NotSame(t, defaultTransport, transport)
+	assert.Same(t, pool, transport.TLSClientConfig.RootCAs)
+	proxyURL, err := transport.Proxy(&http.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, "proxy.internal:3128", proxyURL.Host)
+	if defaultTransport.TLSClientConfig != nil { // Set by the HTTP/2 setup of Clone
+		assert.NotSame(t, defaultTransport.TLSClientConfig, transport.TLSClientConfig)
+		assert.Nil(t, defaultTransport.TLSClientConfig.RootCAs)
+	}
+	assert.Equal(t, reflect.ValueOf(http.ProxyFromEnvironment).Pointer(), reflect.ValueOf(defaultTransport.Proxy).Pointer())
+
+	// Injected transports are cloned as well
+	injected := &http.Transport{}
+	options, err = applyOptions(t, WithTransport(injected), WithProxy("http://proxy.internal:3128"))
+	require.NoError(t, err)
+	httpClient, err = options.buildHTTPClient()
+	require.NoError(t, err)
+	assert.NotSame(t, injected, httpClient.Transport)
+	assert.Nil(t, injected.Proxy)
+}
+
+func TestTransportOptionsRequireHTTPTransport(t *testing.T) {
+	roundTripper := http.RoundTripper(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("unused")
+	}))
+	options, err := applyOptions(t, WithTransport(roundTripper), WithProxy("http://proxy.internal:3128"))
+	require.NoError(t, err)
+	_, err = options.buildHTTPClient()
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr), "got %v", err)
+}
+
+func TestTLSConfigMergesOtherOptions(t *testing.T) {
+	pool := x509.NewCertPool()
+	cert := tls.Certificate{Certificate: [][]byte{[]byte("client")}}
+
+	for name, opts := range map[string][]Option{
+		"before": {WithRootCAs(pool), WithClientCertificate(cert), WithTLSConfig(&tls.Config{ServerName: "guardrails.internal"})},
+		"after":  {WithTLSConfig(&tls.Config{ServerName: "guardrails.internal"}), WithRootCAs(pool), WithClientCertificate(cert)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			options, err := applyOptions(t, opts...)
+			require.NoError(t, err)
+			assert.Equal(t, "guardrails.internal", options.tlsConfig.ServerName)
+			assert.Same(t, pool, options.tlsConfig.RootCAs)
+			assert.Len(t, options.tlsConfig.Certificates, 1)
+		})
+	}
+
+	_, err := applyOptions(t, WithRootCAs(pool), WithTLSConfig(&tls.Config{RootCAs: x509.NewCertPool()}))
+	assert.Error(t, err)
+	_, err = applyOptions(t, WithTLSConfig(&tls.Config{RootCAs: x509.NewCertPool()}), WithRootCAs(pool))
+	assert.Error(t, err)
+}
+
+// roundTripperFunc Function implementing http.RoundTripper
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
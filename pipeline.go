@@ -0,0 +1,202 @@
+package xiangxinai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// PipelineConfig Streaming batch pipeline configuration
+type PipelineConfig struct {
+	// MaxInFlight Items read from the input but not yet emitted in unordered
+	// mode, default the async client's concurrency
+	MaxInFlight int
+	// Ordered Emit results in input order instead of as they complete
+	Ordered bool
+	// ReorderWindow Items read but not yet emitted in ordered mode, bounding
+	// the results buffered behind a slow item, default 4 * MaxInFlight
+	ReorderWindow int
+}
+
+// PipelineResult Pipeline result, tagged with the index and ID of its item
+type PipelineResult struct {
+	Index  int                // Position of the item in the input, starting at 0
+	ID     string             // Item ID
+	Result *GuardrailResponse // Detection result, nil if Err is set
+	Err    error              // Item error, or the error returned by the input iterator
+}
+
+// Pipeline Check items read from a channel, emitting results as they complete
+//
+// Unlike BatchCheckPrompts, the pipeline never holds the whole input: at most
+// MaxInFlight items (ReorderWindow in ordered mode) are read ahead of the
+// consumer, so memory stays bounded for inputs of any size. Requests share the
+// async client's worker pool.
+//
+// The result channel is closed after the input channel is closed and all its
+// items are emitted. To stop early, cancel ctx; results not yet emitted are
+// dropped. The caller must either drain the result channel or cancel ctx.
+//
+// Example:
+//
+//	items := make(chan xiangxinai.BatchItem)
+//	go func() {
+//		defer close(items)
+//		for i, line := range lines {
+//			items <- xiangxinai.NewPromptBatchItem(strconv.Itoa(i), line)
+//		}
+//	}()
+//	for r := range asyncClient.Pipeline(ctx, items, xiangxinai.PipelineConfig{MaxInFlight: 50}) {
+//		if r.Err != nil {
+//			log.Printf("item %s failed: %v", r.ID, r.Err)
+//			continue
+//		}
+//		fmt.Println(r.ID, r.Result.SuggestAction)
+//	}
+func (ac *AsyncClient) Pipeline(ctx context.Context, items <-chan BatchItem, config PipelineConfig) <-chan PipelineResult {
+	return ac.PipelineFunc(ctx, func() (BatchItem, error) {
+		select {
+		case item, ok := <-items:
+			if !ok {
+				return BatchItem{}, io.EOF
+			}
+			return item, nil
+		case <-ctx.Done():
+			return BatchItem{}, ctx.Err()
+		}
+	}, config)
+}
+
+// PipelineFunc Check items returned by an iterator, emitting results as they complete
+//
+// next is called from a single goroutine and returns io.EOF at the end of the
+// input. Any other error is emitted as the last result and ends the input.
+// See Pipeline for details.
+func (ac *AsyncClient) PipelineFunc(ctx context.Context, next func() (BatchItem, error), config PipelineConfig) <-chan PipelineResult {
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = cap(ac.workerPool)
+	}
+	window := config.MaxInFlight
+	if config.Ordered {
+		window = config.ReorderWindow
+		if window <= 0 {
+			window = 4 * config.MaxInFlight
+		}
+		if window < config.MaxInFlight {
+			window = config.MaxInFlight
+		}
+	}
+
+	out := make(chan PipelineResult)
+
+	ac.closeMu.RLock()
+	if ac.closed {
+		ac.closeMu.RUnlock()
+		go func() {
+			defer close(out)
+			select {
+			case out <- PipelineResult{Err: NewXiangxinAIError("async client is closed", nil)}:
+			case <-ctx.Done():
+			}
+		}()
+		return out
+	}
+	ac.wg.Add(1)
+	ac.closeMu.RUnlock()
+
+	// A slot is taken before reading an item and released when its result is emitted
+	slots := make(chan struct{}, window)
+	done := make(chan PipelineResult, window)
+
+	// Read items and start their checks
+	go func() {
+		var wg sync.WaitGroup
+		defer func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		for index := 0; ; index++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			item, err := next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					done <- PipelineResult{Index: index, Err: err}
+				}
+				return
+			}
+
+			wg.Add(1)
+			go func(index int, item BatchItem) {
+				defer wg.Done()
+				result, err := ac.checkPipelineItem(ctx, item)
+				done <- PipelineResult{Index: index, ID: item.ID, Result: result, Err: err}
+			}(index, item)
+		}
+	}()
+
+	// Emit results, draining them after cancellation so the readers can exit
+	go func() {
+		defer ac.wg.Done()
+		defer close(out)
+
+		emit := func(result PipelineResult) {
+			if ctx.Err() == nil {
+				select {
+				case out <- result:
+				case <-ctx.Done():
+				}
+			}
+			<-slots
+		}
+
+		if !config.Ordered {
+			for result := range done {
+				emit(result)
+			}
+			return
+		}
+
+		pending := make(map[int]PipelineResult, window)
+		nextIndex := 0
+		for result := range done {
+			pending[result.Index] = result
+			for {
+				result, ok := pending[nextIndex]
+				if !ok {
+					break
+				}
+				delete(pending, nextIndex)
+				nextIndex++
+				emit(result)
+			}
+		}
+	}()
+
+	return out
+}
+
+// checkPipelineItem Check a pipeline item using a worker slot
+func (ac *AsyncClient) checkPipelineItem(ctx context.Context, item BatchItem) (*GuardrailResponse, error) {
+	request, err := newBatchRequest(item)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return ac.client.createSafeResponse(), nil
+	}
+
+	select {
+	case ac.workerPool <- struct{}{}:
+		defer func() { <-ac.workerPool }()
+	case <-ctx.Done():
+		return nil, NewNetworkError("request failed", ctx.Err())
+	}
+	return ac.client.makeRequest(ctx, "POST", "/guardrails", request)
+}
@@ -0,0 +1,218 @@
+package xiangxinai_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// gateTransport Hold requests whose body contains match until release is closed
+type gateTransport struct {
+	match   string
+	release chan struct{}
+}
+
+func newGateTransport(match string) *gateTransport {
+	return &gateTransport{match: match, release: make(chan struct{})}
+}
+
+func (t *gateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if bytes.Contains(body, []byte(t.match)) {
+		select {
+		case <-t.release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// countingItems Iterator over n prompts, counting the calls to next
+func countingItems(n int, calls *int64) func() (xiangxinai.BatchItem, error) {
+	return func() (xiangxinai.BatchItem, error) {
+		i := int(atomic.AddInt64(calls, 1)) - 1
+		if n >= 0 && i >= n {
+			return xiangxinai.BatchItem{}, io.EOF
+		}
+		return xiangxinai.NewPromptBatchItem(fmt.Sprintf("item-%d", i), fmt.Sprintf("content %d", i)), nil
+	}
+}
+
+// assertCallsStay Assert that calls reaches want and stays there
+func assertCallsStay(t *testing.T, calls *int64, want int64) {
+	t.Helper()
+	require.Eventually(t, func() bool { return atomic.LoadInt64(calls) == want }, time.Second, time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, want, atomic.LoadInt64(calls))
+}
+
+func collect(t *testing.T, results <-chan xiangxinai.PipelineResult) []xiangxinai.PipelineResult {
+	t.Helper()
+	var all []xiangxinai.PipelineResult
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return all
+			}
+			all = append(all, result)
+		case <-timeout:
+			t.Fatal("pipeline did not finish")
+			return nil
+		}
+	}
+}
+
+func TestPipelineCapsInFlightItems(t *testing.T) {
+	gate := newGateTransport("content")
+	client, _ := xiangxintest.NewClient(t, xiangxinai.WithTransport(gate))
+	async := xiangxinai.NewAsyncClientWithClient(client, 10)
+	defer async.Close()
+
+	var calls int64
+	results := async.PipelineFunc(context.Background(), countingItems(10, &calls), xiangxinai.PipelineConfig{MaxInFlight: 3})
+
+	// Three items are read, the fourth waits for a result to be emitted
+	assertCallsStay(t, &calls, 3)
+
+	close(gate.release)
+	all := collect(t, results)
+	require.Len(t, all, 10)
+	seen := map[int]bool{}
+	for _, result := range all {
+		require.NoError(t, result.Err)
+		assert.Equal(t, fmt.Sprintf("item-%d", result.Index), result.ID)
+		seen[result.Index] = true
+	}
+	assert.Len(t, seen, 10)
+}
+
+func TestPipelineOrdered(t *testing.T) {
+	gate := newGateTransport("content 0")
+	client, _ := xiangxintest.NewClient(t, xiangxinai.WithTransport(gate))
+	async := xiangxinai.NewAsyncClientWithClient(client, 10)
+	defer async.Close()
+
+	var calls int64
+	results := async.PipelineFunc(context.Background(), countingItems(10, &calls), xiangxinai.PipelineConfig{
+		MaxInFlight:   2,
+		Ordered:       true,
+		ReorderWindow: 4,
+	})
+
+	// Items behind the slow first one are buffered up to the reorder window
+	assertCallsStay(t, &calls, 4)
+	select {
+	case result := <-results:
+		t.Fatalf("result %d emitted before the first item", result.Index)
+	default:
+	}
+
+	close(gate.release)
+	all := collect(t, results)
+	require.Len(t, all, 10)
+	for i, result := range all {
+		require.NoError(t, result.Err)
+		assert.Equal(t, i, result.Index)
+		assert.Equal(t, fmt.Sprintf("item-%d", i), result.ID)
+	}
+}
+
+func TestPipelineDrainsOnCancel(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.SetLatency(5 * time.Millisecond)
+	async := xiangxinai.NewAsyncClientWithClient(client, 4)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int64
+	results := async.PipelineFunc(ctx, countingItems(-1, &calls), xiangxinai.PipelineConfig{MaxInFlight: 4})
+	for i := 0; i < 3; i++ {
+		result := <-results
+		require.NoError(t, result.Err)
+	}
+
+	// Cancel without reading further: every goroutine of the pipeline exits,
+	// which Close waits for
+	cancel()
+	closed := make(chan struct{})
+	go func() {
+		async.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline goroutines did not exit after cancellation")
+	}
+	for range results {
+	}
+
+	// The input is no longer read
+	stopped := atomic.LoadInt64(&calls)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt64(&calls))
+}
+
+func TestPipelineIteratorError(t *testing.T) {
+	client, _ := xiangxintest.NewClient(t)
+	async := xiangxinai.NewAsyncClientWithClient(client, 10)
+	defer async.Close()
+
+	errBoom := errors.New("boom")
+	var calls int64
+	items := countingItems(2, &calls)
+	next := func() (xiangxinai.BatchItem, error) {
+		item, err := items()
+		if errors.Is(err, io.EOF) {
+			return item, errBoom
+		}
+		return item, err
+	}
+
+	for _, ordered := range []bool{false, true} {
+		atomic.StoreInt64(&calls, 0)
+		all := collect(t, async.PipelineFunc(context.Background(), next, xiangxinai.PipelineConfig{Ordered: ordered}))
+		require.Len(t, all, 3)
+		var failed []xiangxinai.PipelineResult
+		for _, result := range all {
+			if result.Err != nil {
+				failed = append(failed, result)
+			}
+		}
+		require.Len(t, failed, 1)
+		assert.ErrorIs(t, failed[0].Err, errBoom)
+		assert.Equal(t, 2, failed[0].Index)
+		if ordered {
+			assert.Equal(t, failed[0], all[2])
+		}
+	}
+
+	// Pipeline ends at the close of its input channel
+	channel := make(chan xiangxinai.BatchItem, 2)
+	channel <- xiangxinai.NewPromptBatchItem("a", "content a")
+	channel <- xiangxinai.NewPromptBatchItem("b", "content b")
+	close(channel)
+	all := collect(t, async.Pipeline(context.Background(), channel, xiangxinai.PipelineConfig{Ordered: true}))
+	require.Len(t, all, 2)
+	assert.Equal(t, "a", all[0].ID)
+	assert.Equal(t, "b", all[1].ID)
+	assert.NoError(t, all[1].Err)
+}
@@ -0,0 +1,296 @@
+package xiangxinai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ActionLog Locally decided action: let the content pass but log it
+//
+// ActionLog is only produced by a local Policy, never by the API.
+const ActionLog Action = "log"
+
+// DefaultBlockedAnswer Answer of blocked streams and proxied completions when no suggested answer is returned
+const DefaultBlockedAnswer = "Sorry, I can't help with that."
+
+// actionSeverity Severity of policy actions, used to pick the strictest matching rule
+var actionSeverity = map[Action]int{
+	ActionPass:    0,
+	ActionLog:     1,
+	ActionReplace: 2,
+	ActionReject:  3,
+}
+
+// PolicyRule Local policy rule
+//
+// A rule matches when the risk level of Dimension is at least MinRiskLevel and
+// one of Categories is reported for that dimension. Empty Dimension matches any
+// dimension, empty Categories matches any category.
+type PolicyRule struct {
+	Name         string    `json:"name" yaml:"name"`                               // Rule name, shown in decisions
+	Dimension    Dimension `json:"dimension,omitempty" yaml:"dimension"`           // compliance, security or data
+	Categories   []string  `json:"categories,omitempty" yaml:"categories"`         // Risk categories, e.g. "prompt attack"
+	MinRiskLevel RiskLevel `json:"min_risk_level,omitempty" yaml:"min_risk_level"` // Minimum risk level, default low_risk
+	Action       Action    `json:"action" yaml:"action"`                           // pass, log, replace or reject
+	Answer       string    `json:"answer,omitempty" yaml:"answer"`                 // Optional answer for replace/reject
+}
+
+// Policy Local policy, overriding the server's suggest_action per dimension, category and risk level
+//
+// Example policy in YAML:
+//
+//	name: chat-product
+//	default_action: ""  # empty: fall back to the server's suggest_action
+//	rules:
+//	  - name: block-prompt-attack
+//	    dimension: security
+//	    categories: ["prompt attack"]
+//	    min_risk_level: low_risk
+//	    action: reject
+//	  - name: log-compliance
+//	    dimension: compliance
+//	    min_risk_level: medium_risk
+//	    action: log
+type Policy struct {
+	Name          string       `json:"name" yaml:"name"`                               // Policy name
+	DefaultAction Action       `json:"default_action,omitempty" yaml:"default_action"` // Action when no rule matches, empty uses the server's
+	Rules         []PolicyRule `json:"rules" yaml:"rules"`                             // Rules
+}
+
+// Decision Locally decided action for a guardrail response
+type Decision struct {
+	Action       Action      `json:"action"`               // Decided action
+	ServerAction Action      `json:"server_action"`        // Server's suggest_action
+	Answer       *string     `json:"answer,omitempty"`     // Answer for replace/reject
+	Rule         *PolicyRule `json:"rule,omitempty"`       // Rule that fired, nil if none matched
+	Dimension    Dimension   `json:"dimension,omitempty"`  // Dimension that matched
+	Category     string      `json:"category,omitempty"`   // Category that matched
+	RiskLevel    RiskLevel   `json:"risk_level,omitempty"` // Risk level that matched
+	Reason       string      `json:"reason"`               // Human readable explanation
+}
+
+// LoadPolicy Load policy from a .yaml, .yml or .json file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewXiangxinAIError(fmt.Sprintf("failed to read policy file %s", path), err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParsePolicyJSON(data)
+	case ".yaml", ".yml":
+		return ParsePolicyYAML(data)
+	default:
+		return nil, NewValidationError(fmt.Sprintf("unsupported policy file extension: %s", path))
+	}
+}
+
+// ParsePolicyJSON Parse and validate policy from JSON
+func ParsePolicyJSON(data []byte) (*Policy, error) {
+	var policy Policy
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, NewValidationError(fmt.Sprintf("invalid policy JSON: %v", err))
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ParsePolicyYAML Parse and validate policy from YAML
+func ParsePolicyYAML(data []byte) (*Policy, error) {
+	var policy Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, NewValidationError(fmt.Sprintf("invalid policy YAML: %v", err))
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Clone Return a deep copy of the policy
+func (p *Policy) Clone() *Policy {
+	clone := *p
+	clone.Rules = make([]PolicyRule, len(p.Rules))
+	for i, rule := range p.Rules {
+		rule.Categories = append([]string(nil), rule.Categories...)
+		clone.Rules[i] = rule
+	}
+	return &clone
+}
+
+// Validate Validate and normalize the policy
+func (p *Policy) Validate() error {
+	if p.DefaultAction != "" {
+		action, err := parsePolicyAction(string(p.DefaultAction))
+		if err != nil {
+			return err
+		}
+		p.DefaultAction = action
+	}
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+
+		if rule.Dimension != "" {
+			dimension := Dimension(strings.ToLower(strings.TrimSpace(string(rule.Dimension))))
+			if dimension != DimensionCompliance && dimension != DimensionSecurity && dimension != DimensionData {
+				return NewValidationError(fmt.Sprintf("rule %s: unknown dimension: %q", rule.Name, rule.Dimension))
+			}
+			rule.Dimension = dimension
+		}
+
+		if rule.MinRiskLevel == "" {
+			rule.MinRiskLevel = LowRisk
+		}
+		level, err := ParseRiskLevel(string(rule.MinRiskLevel))
+		if err != nil {
+			return NewValidationError(fmt.Sprintf("rule %s: %v", rule.Name, err))
+		}
+		rule.MinRiskLevel = level
+
+		action, err := parsePolicyAction(string(rule.Action))
+		if err != nil {
+			return NewValidationError(fmt.Sprintf("rule %s: %v", rule.Name, err))
+		}
+		rule.Action = action
+	}
+	return nil
+}
+
+// Decide Decide the action for a guardrail response
+//
+// All rules are evaluated and the strictest matching action wins
+// (reject > replace > log > pass); among equally strict rules the first one
+// wins. If no rule matches, DefaultAction is used, or the server's
+// suggest_action if DefaultAction is empty.
+func (p *Policy) Decide(resp *GuardrailResponse) Decision {
+	decision := Decision{
+		ServerAction: resp.SuggestAction,
+	}
+
+	var matched *PolicyRule
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		dimension, category, level, ok := rule.match(resp)
+		if !ok {
+			continue
+		}
+		if matched != nil && actionSeverity[rule.Action] <= actionSeverity[matched.Action] {
+			continue
+		}
+		matched = rule
+		decision.Dimension = dimension
+		decision.Category = category
+		decision.RiskLevel = level
+	}
+
+	switch {
+	case matched != nil:
+		decision.Action = matched.Action
+		decision.Rule = matched
+		decision.Reason = fmt.Sprintf("rule %s matched %s risk %s", matched.Name, decision.Dimension, decision.RiskLevel)
+		if decision.Category != "" {
+			decision.Reason += fmt.Sprintf(" (category %s)", decision.Category)
+		}
+	case p.DefaultAction != "":
+		decision.Action = p.DefaultAction
+		decision.Reason = "no rule matched, using policy default action"
+	default:
+		decision.Action = resp.SuggestAction
+		decision.Reason = "no rule matched, using server suggest_action"
+	}
+
+	if decision.Action == ActionReplace || decision.Action == ActionReject {
+		if matched != nil && matched.Answer != "" {
+			answer := matched.Answer
+			decision.Answer = &answer
+		} else {
+			decision.Answer = resp.SuggestAnswer
+		}
+	}
+	return decision
+}
+
+// match Check if the rule matches the response, returning the matched dimension, category and level
+func (r *PolicyRule) match(resp *GuardrailResponse) (Dimension, string, RiskLevel, bool) {
+	dimensions := Dimensions
+	if r.Dimension != "" {
+		dimensions = []Dimension{r.Dimension}
+	}
+
+	for _, dimension := range dimensions {
+		level := resp.RiskFor(dimension)
+		if level == "" || !level.AtLeast(r.MinRiskLevel) {
+			continue
+		}
+
+		categories := resp.CategoriesFor(dimension)
+		if len(r.Categories) == 0 {
+			category := ""
+			if len(categories) > 0 {
+				category = categories[0]
+			}
+			return dimension, category, level, true
+		}
+		for _, want := range r.Categories {
+			for _, got := range categories {
+				if strings.EqualFold(strings.TrimSpace(want), strings.TrimSpace(got)) {
+					return dimension, got, level, true
+				}
+			}
+		}
+	}
+	return "", "", "", false
+}
+
+// parsePolicyAction Parse a policy action: pass, log, replace or reject
+func parsePolicyAction(s string) (Action, error) {
+	action := Action(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := actionSeverity[action]; !ok {
+		return "", NewValidationError(fmt.Sprintf("unknown policy action: %q", s))
+	}
+	return action, nil
+}
+
+// FinalAction Return the locally decided action if a policy is attached, otherwise the server's
+func (r *GuardrailResponse) FinalAction() Action {
+	if r.Decision != nil {
+		return r.Decision.Action
+	}
+	return r.SuggestAction
+}
+
+// FinalAnswer Return the answer of a blocked result: the policy's answer, the server's suggested answer, or fallback
+func (r *GuardrailResponse) FinalAnswer(fallback string) string {
+	if r.Decision != nil && r.Decision.Answer != nil && *r.Decision.Answer != "" {
+		return *r.Decision.Answer
+	}
+	if r.SuggestAnswer != nil && *r.SuggestAnswer != "" {
+		return *r.SuggestAnswer
+	}
+	return fallback
+}
+
+// applyPolicy Attach the policy decision to the response
+func (c *Client) applyPolicy(resp *GuardrailResponse) *GuardrailResponse {
+	if c.policy != nil && resp != nil {
+		decision := c.policy.Decide(resp)
+		resp.Decision = &decision
+	}
+	return resp
+}
@@ -0,0 +1,469 @@
+// Package proxy provides an OpenAI-compatible reverse proxy that guards chat
+// completions with Xiangxin AI Guardrails.
+//
+// Inbound messages are checked with CheckConversation before the request is
+// forwarded to the upstream endpoint, and each completion is checked with
+// CheckResponseCtx before it is returned. When the action is reject or replace,
+// the suggested answer is returned as a well-formed chat completion instead.
+// Streamed completions are guarded incrementally with a xiangxinai.StreamGuard.
+//
+// Example usage:
+//
+//	client, err := xiangxinai.NewClient("your-api-key")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	p, err := proxy.New(proxy.Config{
+//		Client:         client,
+//		UpstreamURL:    "https://api.openai.com/v1",
+//		UpstreamAPIKey: os.Getenv("OPENAI_API_KEY"),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Fatal(http.ListenAndServe(":8080", p))
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+const (
+	// DefaultMaxBodyBytes Default maximum request and upstream response body size
+	DefaultMaxBodyBytes = 10 << 20
+	// DefaultBlockedAnswer Answer used when the guardrail blocks without a suggested answer
+	DefaultBlockedAnswer = xiangxinai.DefaultBlockedAnswer
+	// chatCompletionsPath OpenAI chat completions path
+	chatCompletionsPath = "/chat/completions"
+)
+
+// Response headers set by the proxy
+const (
+	// HeaderInputAction Guardrail action for the inbound messages
+	HeaderInputAction = "X-Xiangxin-Input-Action"
+	// HeaderOutputAction Guardrail action for the completion
+	HeaderOutputAction = "X-Xiangxin-Output-Action"
+	// HeaderGuardrailID Guardrail request ID of the check that blocked the request
+	HeaderGuardrailID = "X-Xiangxin-Guardrail-ID"
+)
+
+// Config Proxy configuration
+type Config struct {
+	Client         *xiangxinai.Client           // Guardrail client (required)
+	UpstreamURL    string                       // Upstream OpenAI-compatible base URL, e.g. https://api.openai.com/v1 (required)
+	UpstreamAPIKey string                       // Upstream API key; if empty the inbound Authorization header is forwarded
+	HTTPClient     *http.Client                 // HTTP client for upstream requests, default a client with a 120s timeout
+	Model          string                       // Guardrail model for input checks, default xiangxinai.DefaultModel
+	SkipOutput     bool                         // Skip output checks of the completion
+	MaxBodyBytes   int64                        // Maximum body size, default DefaultMaxBodyBytes
+	BlockedAnswer  string                       // Answer used when no suggested answer is returned, default DefaultBlockedAnswer
+	Stream         xiangxinai.StreamGuardConfig // Output check cadence of streamed completions
+}
+
+// Proxy Guarded OpenAI-compatible reverse proxy, implements http.Handler
+type Proxy struct {
+	config Config
+}
+
+// New Create new proxy
+func New(config Config) (*Proxy, error) {
+	if config.Client == nil {
+		return nil, xiangxinai.NewValidationError("guardrail client cannot be nil")
+	}
+	if config.UpstreamURL == "" {
+		return nil, xiangxinai.NewValidationError("upstream URL cannot be empty")
+	}
+	config.UpstreamURL = strings.TrimSuffix(config.UpstreamURL, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 120 * time.Second}
+	}
+	if config.Model == "" {
+		config.Model = xiangxinai.DefaultModel
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if config.BlockedAnswer == "" {
+		config.BlockedAnswer = DefaultBlockedAnswer
+	}
+	if config.Stream.BlockedAnswer == "" {
+		config.Stream.BlockedAnswer = config.BlockedAnswer
+	}
+	return &Proxy{config: config}, nil
+}
+
+// chatCompletionRequest Fields of a chat completion request used by the proxy
+type chatCompletionRequest struct {
+	Model    string            `json:"model"`
+	Messages []json.RawMessage `json:"messages"`
+	Stream   bool              `json:"stream"`
+	User     string            `json:"user"`
+}
+
+// chatCompletionResponse Fields of a chat completion response used by the proxy
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content *string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// ServeHTTP Handle POST .../chat/completions requests
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, chatCompletionsPath) {
+		writeError(w, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("unknown path: %s", r.URL.Path))
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, p.config.MaxBodyBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "failed to read request body")
+		return
+	}
+	if int64(len(body)) > p.config.MaxBodyBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "request body too large")
+		return
+	}
+
+	var req chatCompletionRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	messages, err := guardedMessages(req.Messages)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	ctx := r.Context()
+
+	// Check inbound messages
+	if len(messages) > 0 {
+		result, err := p.config.Client.CheckConversationWithModel(ctx, messages, p.config.Model, req.User)
+		if err != nil {
+			writeGuardrailError(w, err)
+			return
+		}
+		w.Header().Set(HeaderInputAction, string(result.FinalAction()))
+		if isBlocking(result) {
+			w.Header().Set(HeaderGuardrailID, result.ID)
+			if req.Stream {
+				p.writeBlockedStream(w, req.Model, result)
+			} else {
+				p.writeBlocked(w, req.Model, result)
+			}
+			return
+		}
+	}
+
+	// Forward to upstream
+	upstreamReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.UpstreamURL+chatCompletionsPath, bytes.NewReader(body))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "proxy_error", err.Error())
+		return
+	}
+	upstreamReq.Header.Set("Content-Type", "application/json")
+	if p.config.UpstreamAPIKey != "" {
+		upstreamReq.Header.Set("Authorization", "Bearer "+p.config.UpstreamAPIKey)
+	} else if auth := r.Header.Get("Authorization"); auth != "" {
+		upstreamReq.Header.Set("Authorization", auth)
+	}
+
+	upstreamResp, err := p.config.HTTPClient.Do(upstreamReq)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "upstream_error", fmt.Sprintf("upstream request failed: %v", err))
+		return
+	}
+	defer upstreamResp.Body.Close()
+
+	if req.Stream && upstreamResp.StatusCode >= 200 && upstreamResp.StatusCode < 300 {
+		if p.config.SkipOutput {
+			relayStream(w, upstreamResp, upstreamResp.Body)
+			return
+		}
+		p.serveStream(w, r, upstreamResp, lastUserText(messages), req.User)
+		return
+	}
+
+	respBody, err := io.ReadAll(io.LimitReader(upstreamResp.Body, p.config.MaxBodyBytes+1))
+	if err != nil || int64(len(respBody)) > p.config.MaxBodyBytes {
+		writeError(w, http.StatusBadGateway, "upstream_error", "failed to read upstream response")
+		return
+	}
+
+	if upstreamResp.StatusCode < 200 || upstreamResp.StatusCode >= 300 || p.config.SkipOutput {
+		copyResponse(w, upstreamResp, respBody)
+		return
+	}
+
+	// Check completion
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(respBody, &completion); err != nil {
+		writeError(w, http.StatusBadGateway, "upstream_error", "invalid upstream response")
+		return
+	}
+
+	prompt := lastUserText(messages)
+	var replacements map[int]string
+	outputAction := xiangxinai.ActionPass
+	for i, choice := range completion.Choices {
+		if choice.Message.Content == nil || strings.TrimSpace(*choice.Message.Content) == "" {
+			continue
+		}
+		result, err := p.config.Client.CheckResponseCtx(ctx, prompt, *choice.Message.Content, req.User)
+		if err != nil {
+			writeGuardrailError(w, err)
+			return
+		}
+		if isBlocking(result) {
+			if replacements == nil {
+				replacements = make(map[int]string)
+			}
+			replacements[i] = p.answer(result)
+			outputAction = result.FinalAction()
+			w.Header().Set(HeaderGuardrailID, result.ID)
+		} else if outputAction == xiangxinai.ActionPass {
+			outputAction = result.FinalAction()
+		}
+	}
+	w.Header().Set(HeaderOutputAction, string(outputAction))
+
+	if len(replacements) > 0 {
+		respBody, err = replaceChoiceContents(respBody, replacements)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "upstream_error", "invalid upstream response")
+			return
+		}
+	}
+	copyResponse(w, upstreamResp, respBody)
+}
+
+// serveStream Relay a streamed completion, stopping it when the output check blocks
+func (p *Proxy) serveStream(w http.ResponseWriter, r *http.Request, upstreamResp *http.Response, prompt, userID string) {
+	ctx := r.Context()
+	guard := p.config.Client.NewStreamGuard(ctx, prompt, p.config.Stream, userID)
+	stream := guard.GuardSSE(ctx, upstreamResp.Body)
+	defer stream.Close()
+	relayStream(w, upstreamResp, stream)
+}
+
+// relayStream Relay a streamed response, flushing every read
+func relayStream(w http.ResponseWriter, upstreamResp *http.Response, stream io.Reader) {
+	for _, key := range []string{"Content-Type", "X-Request-Id", "Openai-Organization", "Openai-Processing-Ms"} {
+		if value := upstreamResp.Header.Get(key); value != "" {
+			w.Header().Set(key, value)
+		}
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(upstreamResp.StatusCode)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 4096)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// guardedMessages Convert OpenAI messages to guardrail messages
+//
+// Only user, system and assistant messages are checked; developer messages are
+// treated as system messages and other roles (e.g. tool) are skipped.
+func guardedMessages(raw []json.RawMessage) ([]*xiangxinai.Message, error) {
+	messages := make([]*xiangxinai.Message, 0, len(raw))
+	for i, data := range raw {
+		var msg struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("invalid message %d: %v", i, err)
+		}
+
+		role := msg.Role
+		if role == "developer" {
+			role = "system"
+		}
+		if role != "user" && role != "system" && role != "assistant" {
+			continue
+		}
+
+		var content xiangxinai.MessageContent
+		if len(msg.Content) > 0 {
+			if err := json.Unmarshal(msg.Content, &content); err != nil {
+				return nil, fmt.Errorf("invalid content of message %d: %v", i, err)
+			}
+		}
+		messages = append(messages, &xiangxinai.Message{Role: role, Content: content})
+	}
+	return messages, nil
+}
+
+// lastUserText Return the text of the last user message
+func lastUserText(messages []*xiangxinai.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content.String()
+		}
+	}
+	return ""
+}
+
+// isBlocking Check if the guardrail action requires replacing the content
+func isBlocking(result *xiangxinai.GuardrailResponse) bool {
+	action := result.FinalAction()
+	return action == xiangxinai.ActionReject || action == xiangxinai.ActionReplace
+}
+
+// answer Return the answer for a blocked result
+func (p *Proxy) answer(result *xiangxinai.GuardrailResponse) string {
+	return result.FinalAnswer(p.config.BlockedAnswer)
+}
+
+// writeBlocked Write the suggested answer as a chat completion
+func (p *Proxy) writeBlocked(w http.ResponseWriter, model string, result *xiangxinai.GuardrailResponse) {
+	completion := map[string]interface{}{
+		"id":      "chatcmpl-" + result.ID,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []map[string]interface{}{
+			{
+				"index": 0,
+				"message": map[string]interface{}{
+					"role":    "assistant",
+					"content": p.answer(result),
+				},
+				"finish_reason": "stop",
+			},
+		},
+		"usage": map[string]int{
+			"prompt_tokens":     0,
+			"completion_tokens": 0,
+			"total_tokens":      0,
+		},
+	}
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// writeBlockedStream Write the suggested answer as a streamed chat completion chunk
+//
+// Streaming clients expect text/event-stream, so the answer is sent as a single
+// chunk with finish_reason "content_filter", followed by "data: [DONE]".
+func (p *Proxy) writeBlockedStream(w http.ResponseWriter, model string, result *xiangxinai.GuardrailResponse) {
+	chunk := map[string]interface{}{
+		"id":      "chatcmpl-" + result.ID,
+		"object":  "chat.completion.chunk",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []map[string]interface{}{
+			{
+				"index": 0,
+				"delta": map[string]interface{}{
+					"role":    "assistant",
+					"content": p.answer(result),
+				},
+				"finish_reason": "content_filter",
+			},
+		},
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "proxy_error", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", data)
+}
+
+// replaceChoiceContents Replace message contents of the given choices, keeping all other fields
+func replaceChoiceContents(body []byte, replacements map[int]string) ([]byte, error) {
+	var completion map[string]interface{}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return nil, err
+	}
+	choices, _ := completion["choices"].([]interface{})
+	for i, answer := range replacements {
+		if i >= len(choices) {
+			continue
+		}
+		choice, _ := choices[i].(map[string]interface{})
+		if choice == nil {
+			continue
+		}
+		message, _ := choice["message"].(map[string]interface{})
+		if message == nil {
+			message = map[string]interface{}{"role": "assistant"}
+			choice["message"] = message
+		}
+		message["content"] = answer
+		delete(message, "tool_calls")
+		choice["finish_reason"] = "stop"
+	}
+	return json.Marshal(completion)
+}
+
+// copyResponse Write the upstream response with the given body
+func copyResponse(w http.ResponseWriter, resp *http.Response, body []byte) {
+	for _, key := range []string{"Content-Type", "X-Request-Id", "Openai-Organization", "Openai-Processing-Ms"} {
+		if value := resp.Header.Get(key); value != "" {
+			w.Header().Set(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+}
+
+// writeGuardrailError Write a guardrail check error
+func writeGuardrailError(w http.ResponseWriter, err error) {
+	var validationErr *xiangxinai.ValidationError
+	if errors.As(err, &validationErr) {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	writeError(w, http.StatusBadGateway, "guardrail_error", fmt.Sprintf("guardrail check failed: %v", err))
+}
+
+// writeError Write an OpenAI style error response
+func writeError(w http.ResponseWriter, status int, errType, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+		},
+	})
+}
+
+// writeJSON Write a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
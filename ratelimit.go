@@ -0,0 +1,162 @@
+package xiangxinai
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitMinFactor Lowest fraction of the configured rate the limiter adapts down to
+	rateLimitMinFactor = 1.0 / 16
+	// rateLimitRecoverFactor Fraction of the configured rate recovered after each successful response
+	rateLimitRecoverFactor = 0.05
+)
+
+// ErrRateLimited Returned (wrapped in a RateLimitError) when the client-side rate limiter rejects a request
+var ErrRateLimited = errors.New("client rate limit exceeded")
+
+// RateLimitConfig Client-side rate limiter configuration, used with WithRateLimit
+//
+// The limiter is a token bucket shared by all requests of the client,
+// including retries. It adapts to the server: a 429 response halves the rate
+// (recovering gradually on success) and pauses requests for Retry-After, and
+// X-RateLimit-Remaining: 0 pauses requests until X-RateLimit-Reset.
+//
+// By default callers wait for a token, failing with a RateLimitError if the
+// token cannot be obtained before their context deadline. With FailFast,
+// callers fail immediately instead.
+type RateLimitConfig struct {
+	QPS      float64 // Sustained requests per second (required)
+	Burst    int     // Maximum burst size, default QPS rounded up
+	FailFast bool    // Fail with RateLimitError instead of waiting for a token
+}
+
+// rateLimiter Adaptive token bucket
+type rateLimiter struct {
+	baseRate float64
+	burst    float64
+	failFast bool
+
+	mu          sync.Mutex
+	rate        float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// newRateLimiter Create rate limiter with a full bucket
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	burst := float64(config.Burst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(config.QPS))
+	}
+	return &rateLimiter{
+		baseRate: config.QPS,
+		burst:    burst,
+		failFast: config.FailFast,
+		rate:     config.QPS,
+		tokens:   burst,
+		last:     time.Now(),
+	}
+}
+
+// wait Take a token, waiting until it is available unless failing fast
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.refillLocked(now)
+
+	var delay time.Duration
+	if now.Before(l.pausedUntil) {
+		delay = l.pausedUntil.Sub(now)
+	}
+	l.tokens--
+	if l.tokens < 0 {
+		if d := time.Duration(-l.tokens / l.rate * float64(time.Second)); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		deadline, hasDeadline := ctx.Deadline()
+		if l.failFast || (hasDeadline && now.Add(delay).After(deadline)) {
+			l.tokens++
+			l.mu.Unlock()
+			err := NewRateLimitError("rate limit exceeded")
+			err.Cause = ErrRateLimited
+			return err
+		}
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return NewNetworkError("request failed", ctx.Err())
+	}
+}
+
+// observe Adapt the rate to a response
+func (l *rateLimiter) observe(statusCode int, header http.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.refillLocked(now)
+
+	if statusCode == http.StatusTooManyRequests {
+		l.rate = math.Max(l.rate/2, l.baseRate*rateLimitMinFactor)
+		l.pauseLocked(now.Add(parseRetryAfter(header.Get("Retry-After"))))
+	} else if statusCode >= 200 && statusCode < 300 && l.rate < l.baseRate {
+		l.rate = math.Min(l.rate+l.baseRate*rateLimitRecoverFactor, l.baseRate)
+	}
+
+	if remaining := strings.TrimSpace(header.Get("X-RateLimit-Remaining")); remaining == "0" {
+		if reset := parseRateLimitReset(header.Get("X-RateLimit-Reset"), now); reset > 0 {
+			l.pauseLocked(now.Add(reset))
+		}
+	}
+}
+
+// refillLocked Add the tokens accumulated since the last update, must be called with mu held
+func (l *rateLimiter) refillLocked(now time.Time) {
+	elapsed := now.Sub(l.last).Seconds()
+	if elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed*l.rate)
+		l.last = now
+	}
+}
+
+// pauseLocked Pause requests until t, must be called with mu held
+func (l *rateLimiter) pauseLocked(t time.Time) {
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+	}
+}
+
+// parseRateLimitReset Parse X-RateLimit-Reset as seconds from now or a Unix timestamp
+func parseRateLimitReset(value string, now time.Time) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	// Values larger than a year are Unix timestamps
+	if seconds > 365*24*3600 {
+		return time.Unix(int64(seconds), 0).Sub(now)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
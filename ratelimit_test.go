@@ -0,0 +1,169 @@
+package xiangxinai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// advance Move the limiter clock back by d, as if d had elapsed
+func (l *rateLimiter) advance(d time.Duration) {
+	l.mu.Lock()
+	l.last = l.last.Add(-d)
+	l.mu.Unlock()
+}
+
+// currentRate Return the adapted rate
+func (l *rateLimiter) currentRate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// pause Return the remaining pause
+func (l *rateLimiter) pause() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Until(l.pausedUntil)
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{QPS: 10, Burst: 2, FailFast: true})
+	ctx := context.Background()
+
+	require.NoError(t, limiter.wait(ctx))
+	require.NoError(t, limiter.wait(ctx))
+	err := limiter.wait(ctx)
+	var rateLimitErr *RateLimitError
+	assert.True(t, errors.As(err, &rateLimitErr), "got %v", err)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+
+	// 100ms refill one token at 10 QPS
+	limiter.advance(100 * time.Millisecond)
+	require.NoError(t, limiter.wait(ctx))
+	assert.Error(t, limiter.wait(ctx))
+
+	// The bucket does not grow beyond the burst
+	limiter.advance(time.Hour)
+	require.NoError(t, limiter.wait(ctx))
+	require.NoError(t, limiter.wait(ctx))
+	assert.Error(t, limiter.wait(ctx))
+}
+
+func TestRateLimiterWaitsForToken(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{QPS: 20, Burst: 1})
+	ctx := context.Background()
+
+	require.NoError(t, limiter.wait(ctx))
+	start := time.Now()
+	require.NoError(t, limiter.wait(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	// A cancelled wait returns its token
+	limiter = newRateLimiter(RateLimitConfig{QPS: 0.01, Burst: 1})
+	require.NoError(t, limiter.wait(ctx))
+	cancelled, cancel := context.WithCancel(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := limiter.wait(cancelled)
+	assert.True(t, errors.Is(err, context.Canceled), "got %v", err)
+	limiter.mu.Lock()
+	assert.InDelta(t, 0, limiter.tokens, 0.01)
+	limiter.mu.Unlock()
+}
+
+func TestRateLimiterFailsBeforeDeadline(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{QPS: 0.5, Burst: 1})
+	require.NoError(t, limiter.wait(context.Background()))
+
+	// The next token is two seconds away, beyond the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := limiter.wait(ctx)
+	assert.True(t, errors.Is(err, ErrRateLimited), "got %v", err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestRateLimiterAdaptsTo429(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{QPS: 16, Burst: 16})
+
+	limiter.observe(http.StatusTooManyRequests, http.Header{})
+	assert.Equal(t, 8.0, limiter.currentRate())
+	limiter.observe(http.StatusTooManyRequests, http.Header{})
+	assert.Equal(t, 4.0, limiter.currentRate())
+	for i := 0; i < 10; i++ {
+		limiter.observe(http.StatusTooManyRequests, http.Header{})
+	}
+	assert.Equal(t, 1.0, limiter.currentRate()) // Floor of QPS/16
+
+	// Each success recovers 5% of the configured rate
+	limiter.observe(http.StatusOK, http.Header{})
+	assert.InDelta(t, 1.8, limiter.currentRate(), 1e-9)
+	limiter.observe(http.StatusBadRequest, http.Header{})
+	assert.InDelta(t, 1.8, limiter.currentRate(), 1e-9)
+	for i := 0; i < 30; i++ {
+		limiter.observe(http.StatusOK, http.Header{})
+	}
+	assert.Equal(t, 16.0, limiter.currentRate())
+}
+
+func TestRateLimiterPauses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		pause  time.Duration
+	}{
+		{"retry after", http.StatusTooManyRequests, http.Header{"Retry-After": {"2"}}, 2 * time.Second},
+		{"reset seconds", http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"3"}}, 3 * time.Second},
+		{"reset timestamp", http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {strconv.FormatInt(time.Now().Add(4*time.Second).Unix(), 10)}}, 4 * time.Second},
+		{"remaining", http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"5"}, "X-Ratelimit-Reset": {"3"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newRateLimiter(RateLimitConfig{QPS: 100, FailFast: true})
+			limiter.observe(tt.status, tt.header)
+			if tt.pause == 0 {
+				assert.LessOrEqual(t, limiter.pause(), time.Duration(0))
+				assert.NoError(t, limiter.wait(context.Background()))
+				return
+			}
+			assert.InDelta(t, tt.pause.Seconds(), limiter.pause().Seconds(), 1)
+
+			// Paused callers fail fast despite the full bucket
+			err := limiter.wait(context.Background())
+			assert.True(t, errors.Is(err, ErrRateLimited), "got %v", err)
+		})
+	}
+}
+
+func TestRateLimitPausesClientAfter429(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient("test-key", WithBaseURL(server.URL), WithMaxRetries(0),
+		WithRateLimit(RateLimitConfig{QPS: 100, FailFast: true}))
+	require.NoError(t, err)
+
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	var rateLimitErr *RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr), "got %v", err)
+	assert.False(t, errors.Is(err, ErrRateLimited))
+
+	// Paused for Retry-After without calling the API
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	assert.True(t, errors.Is(err, ErrRateLimited), "got %v", err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,394 @@
+package xiangxinai
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in sensitive data types, used in placeholders such as [PHONE_1]
+const (
+	PIIIDCard    = "ID_CARD"   // Chinese resident ID card number, checksum validated
+	PIIPhone     = "PHONE"     // Chinese mobile number or international number with country code
+	PIIBankCard  = "BANK_CARD" // Bank card number, Luhn validated
+	PIIEmail     = "EMAIL"     // Email address
+	PIIIPAddress = "IP"        // IPv4 or IPv6 address
+	PIIAPIKey    = "API_KEY"   // Cloud provider and SaaS API keys, private keys
+)
+
+// detectorTypePattern Valid detector type, used in placeholders
+var detectorTypePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Detector Sensitive data detector used by the Redactor
+type Detector struct {
+	Type     string                  // Placeholder type, upper case, e.g. PHONE
+	Pattern  *regexp.Regexp          // Candidate pattern
+	Validate func(match string) bool // Optional check of a candidate, e.g. a checksum
+}
+
+// NewRegexDetector Create a detector of the given type from a regular expression
+//
+// Example:
+//
+//	employeeID, err := xiangxinai.NewRegexDetector("EMPLOYEE_ID", `\bEMP-\d{6}\b`)
+func NewRegexDetector(typ, pattern string) (Detector, error) {
+	if !detectorTypePattern.MatchString(typ) {
+		return Detector{}, NewValidationError(fmt.Sprintf("invalid detector type %q: use upper case letters, digits and underscores", typ))
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Detector{}, NewValidationError(fmt.Sprintf("invalid detector pattern for %s: %v", typ, err))
+	}
+	return Detector{Type: typ, Pattern: re}, nil
+}
+
+var (
+	idCardPattern    = regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`)
+	mobilePattern    = regexp.MustCompile(`(?:(?:\+|\b)86[- ]?|\b)1[3-9]\d{9}\b`)
+	intlPhonePattern = regexp.MustCompile(`\+[1-9]\d{0,2}(?:[ -]?\d){7,12}\b`)
+	bankCardPattern  = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	emailPattern     = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	ipv4Pattern      = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+	ipv6Pattern      = regexp.MustCompile(`(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`)
+	apiKeyPattern    = regexp.MustCompile(strings.Join([]string{
+		`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,  // AWS access key ID
+		`\bLTAI[0-9A-Za-z]{12,20}\b`,     // Alibaba Cloud AccessKey ID
+		`\bAKID[0-9A-Za-z]{13,40}\b`,     // Tencent Cloud SecretId
+		`\bAIza[0-9A-Za-z_-]{35}`,        // Google API key
+		`\bsk-[A-Za-z0-9_-]{20,}`,        // OpenAI style secret key
+		`\bgh[pousr]_[A-Za-z0-9]{36,}\b`, // GitHub token
+		`\bxox[abprs]-[A-Za-z0-9-]{10,}`, // Slack token
+		`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`, // PEM private key
+	}, "|"))
+)
+
+// DefaultDetectors Return the built-in detectors: ID card, phone, bank card, email, IP address and API key
+//
+// Overlapping matches are resolved in favor of the earliest, then the longest,
+// then the first listed detector.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		{Type: PIIAPIKey, Pattern: apiKeyPattern},
+		{Type: PIIEmail, Pattern: emailPattern},
+		{Type: PIIIDCard, Pattern: idCardPattern, Validate: validIDCard},
+		{Type: PIIPhone, Pattern: mobilePattern},
+		{Type: PIIPhone, Pattern: intlPhonePattern},
+		{Type: PIIBankCard, Pattern: bankCardPattern, Validate: validBankCard},
+		{Type: PIIIPAddress, Pattern: ipv4Pattern},
+		{Type: PIIIPAddress, Pattern: ipv6Pattern, Validate: validIPv6},
+	}
+}
+
+// RedactionConfig Local redaction settings, used with WithRedaction
+type RedactionConfig struct {
+	Detectors []Detector // Detectors, nil uses DefaultDetectors
+}
+
+// Redactor Replaces sensitive values in text with typed placeholders such as [PHONE_1]
+//
+// Set on a client with WithRedaction, the redactor runs on every Check*
+// request before it is sent, so matched values never leave the process.
+type Redactor struct {
+	detectors []Detector
+}
+
+// NewRedactor Create a redactor, no detectors uses DefaultDetectors
+func NewRedactor(detectors ...Detector) *Redactor {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors()
+	}
+	return &Redactor{detectors: detectors}
+}
+
+// Redact Replace sensitive values in text, returning the redacted text and the mapping
+//
+// Example:
+//
+//	redacted, redactions := xiangxinai.NewRedactor().Redact("Call me at 13812345678")
+//	fmt.Println(redacted)                     // Call me at [PHONE_1]
+//	fmt.Println(redactions.Restore(redacted)) // Call me at 13812345678
+func (r *Redactor) Redact(text string) (string, *Redactions) {
+	redactions := newRedactions()
+	return r.redact(text, redactions), redactions
+}
+
+// redactionMatch Detected value in a text
+type redactionMatch struct {
+	start, end int
+	typ        string
+	order      int
+	category   string // Reported data category, set by Mask
+}
+
+// redact Replace sensitive values in text, adding them to redactions
+func (r *Redactor) redact(text string, redactions *Redactions) string {
+	matches := resolveMatches(detectMatches(text, r.detectors))
+	if len(matches) == 0 {
+		return text
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		builder.WriteString(text[last:match.start])
+		builder.WriteString(redactions.placeholder(match.typ, text[match.start:match.end]))
+		last = match.end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// detectMatches Return the validated matches of the detectors in text
+func detectMatches(text string, detectors []Detector) []redactionMatch {
+	var matches []redactionMatch
+	for order, detector := range detectors {
+		for _, loc := range detector.Pattern.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if detector.Validate != nil && !detector.Validate(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matches = append(matches, redactionMatch{start: loc[0], end: loc[1], typ: detector.Type, order: order})
+		}
+	}
+	return matches
+}
+
+// resolveMatches Sort matches by position and drop overlapping ones
+//
+// Of overlapping matches, the earliest, then the longest, then the one with the
+// lowest order is kept.
+func resolveMatches(matches []redactionMatch) []redactionMatch {
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.end != b.end {
+			return a.end > b.end
+		}
+		return a.order < b.order
+	})
+
+	resolved := matches[:0]
+	last := 0
+	for _, match := range matches {
+		if match.start < last {
+			continue // Overlaps a previous match
+		}
+		resolved = append(resolved, match)
+		last = match.end
+	}
+	return resolved
+}
+
+// redactRequest Return a redacted copy of a check request body, leaving the original unchanged
+func (r *Redactor) redactRequest(body interface{}, redactions *Redactions) interface{} {
+	switch data := body.(type) {
+	case *InputRequest:
+		redacted := *data
+		redacted.Input = r.redact(data.Input, redactions)
+		return &redacted
+	case *OutputRequest:
+		redacted := *data
+		redacted.Input = r.redact(data.Input, redactions)
+		redacted.Output = r.redact(data.Output, redactions)
+		return &redacted
+	case *GuardrailRequest:
+		redacted := *data
+		redacted.Messages = make([]*Message, len(data.Messages))
+		for i, msg := range data.Messages {
+			if msg == nil {
+				continue
+			}
+			redacted.Messages[i] = &Message{Role: msg.Role, Content: r.redactContent(msg.Content, redactions)}
+		}
+		return &redacted
+	default:
+		return body
+	}
+}
+
+// redactContent Return a redacted copy of message content; image parts are kept
+func (r *Redactor) redactContent(content MessageContent, redactions *Redactions) MessageContent {
+	if !content.IsMultimodal() {
+		return NewTextContent(r.redact(content.Text, redactions))
+	}
+	parts := make([]ContentPart, len(content.Parts))
+	for i, part := range content.Parts {
+		if text, ok := part.(*TextPart); ok && text != nil {
+			parts[i] = NewTextPart(r.redact(text.Text, redactions))
+			continue
+		}
+		parts[i] = part
+	}
+	return NewPartsContent(parts...)
+}
+
+// Redactions Mapping of the placeholders of a redacted request to the original values
+//
+// A nil *Redactions has no placeholders and restores text unchanged.
+type Redactions struct {
+	values       map[string]string // Placeholder to original value
+	placeholders map[string]string // Type and original value to placeholder
+	counts       map[string]int    // Placeholders per type
+}
+
+// newRedactions Create empty redactions
+func newRedactions() *Redactions {
+	return &Redactions{
+		values:       make(map[string]string),
+		placeholders: make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+// placeholder Return the placeholder of a value, reusing it for repeated values
+func (r *Redactions) placeholder(typ, value string) string {
+	key := typ + "\x00" + value
+	if placeholder, ok := r.placeholders[key]; ok {
+		return placeholder
+	}
+	r.counts[typ]++
+	placeholder := fmt.Sprintf("[%s_%d]", typ, r.counts[typ])
+	r.placeholders[key] = placeholder
+	r.values[placeholder] = value
+	return placeholder
+}
+
+// Len Return the number of redacted values
+func (r *Redactions) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.values)
+}
+
+// Value Return the original value of a placeholder
+func (r *Redactions) Value(placeholder string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	value, ok := r.values[placeholder]
+	return value, ok
+}
+
+// Map Return a copy of the placeholder to original value mapping
+func (r *Redactions) Map() map[string]string {
+	values := make(map[string]string, r.Len())
+	if r != nil {
+		for placeholder, value := range r.values {
+			values[placeholder] = value
+		}
+	}
+	return values
+}
+
+// Restore Replace the placeholders in text with the original values
+func (r *Redactions) Restore(text string) string {
+	if r.Len() == 0 {
+		return text
+	}
+	oldnew := make([]string, 0, 2*len(r.values))
+	for placeholder, value := range r.values {
+		oldnew = append(oldnew, placeholder, value)
+	}
+	return strings.NewReplacer(oldnew...).Replace(text)
+}
+
+// RestoredAnswer Return the suggested answer with redacted values restored, empty if there is none
+func (r *GuardrailResponse) RestoredAnswer() string {
+	if r.SuggestAnswer == nil {
+		return ""
+	}
+	return r.Redactions.Restore(*r.SuggestAnswer)
+}
+
+// redactInterceptor Redact the request before it reaches the cache and the API
+func (c *Client) redactInterceptor(ctx context.Context, req *Request, next Next) (*GuardrailResponse, error) {
+	if batch, ok := req.Body.(*BatchRequest); ok {
+		return c.redactBatch(ctx, req, batch, next)
+	}
+	redactions := newRedactions()
+	req.Body = c.redactor.redactRequest(req.Body, redactions)
+	result, err := next(ctx, req)
+	if result != nil && redactions.Len() > 0 {
+		result.Redactions = redactions
+	}
+	return result, err
+}
+
+// redactBatch Redact each item of a batch request, attaching its redactions to the item result
+func (c *Client) redactBatch(ctx context.Context, req *Request, batch *BatchRequest, next Next) (*GuardrailResponse, error) {
+	redacted := &BatchRequest{Items: make([]BatchRequestItem, len(batch.Items))}
+	redactions := make(map[string]*Redactions, len(batch.Items))
+	for i, item := range batch.Items {
+		if item.GuardrailRequest == nil {
+			redacted.Items[i] = item
+			continue
+		}
+		itemRedactions := newRedactions()
+		request, _ := c.redactor.redactRequest(item.GuardrailRequest, itemRedactions).(*GuardrailRequest)
+		redacted.Items[i] = BatchRequestItem{ID: item.ID, GuardrailRequest: request}
+		if itemRedactions.Len() > 0 {
+			redactions[item.ID] = itemRedactions
+		}
+	}
+	req.Body = redacted
+
+	result, err := next(ctx, req)
+	if result != nil {
+		for id, item := range result.batchResults() {
+			if item.Result != nil && redactions[id] != nil {
+				item.Result.Redactions = redactions[id]
+			}
+		}
+	}
+	return result, err
+}
+
+// validIDCard Check the checksum of an 18-digit Chinese resident ID card number
+func validIDCard(id string) bool {
+	weights := [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	const checkCodes = "10X98765432"
+	sum := 0
+	for i, weight := range weights {
+		sum += int(id[i]-'0') * weight
+	}
+	return checkCodes[sum%11] == strings.ToUpper(id[17:])[0]
+}
+
+// validBankCard Check the length and Luhn checksum of a bank card number, ignoring separators
+func validBankCard(card string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(card)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		digit := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// validIPv6 Check that a candidate is an IPv6 address, not e.g. a time of day or a scoped name like a::b
+func validIPv6(candidate string) bool {
+	groups := 0
+	for _, group := range strings.Split(candidate, ":") {
+		if group != "" {
+			groups++
+		}
+	}
+	return groups >= 2 && net.ParseIP(candidate) != nil
+}
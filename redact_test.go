@@ -0,0 +1,34 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestRedactionKeepsValuesLocal(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithRedaction(xiangxinai.RedactionConfig{}))
+	server.OnContent(`\[PHONE_1\]`, xiangxintest.Verdict{
+		Dimension:  xiangxinai.DimensionData,
+		Categories: []string{"PHONE_NUMBER"},
+		Action:     xiangxinai.ActionReplace,
+		Answer:     "Call [PHONE_1] later",
+	})
+
+	result, err := client.CheckPrompt(context.Background(), "My phone is 13812345678")
+	require.NoError(t, err)
+
+	request, ok := server.LastRequest()
+	require.True(t, ok)
+	assert.NotContains(t, string(request.Body), "13812345678")
+	assert.Equal(t, "My phone is [PHONE_1]", request.Messages[0].Content.String())
+
+	assert.Equal(t, 1, result.Redactions.Len())
+	assert.Equal(t, "13812345678", result.Redactions.Restore("[PHONE_1]"))
+	assert.Equal(t, "Call 13812345678 later", result.RestoredAnswer())
+}
@@ -0,0 +1,134 @@
+package xiangxinai
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetryBaseDelay Default base delay of the exponential backoff
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay Default maximum delay between two attempts
+	DefaultRetryMaxDelay = 8 * time.Second
+	// DefaultRetryMaxElapsed Default maximum total time spent on retries
+	DefaultRetryMaxElapsed = 30 * time.Second
+)
+
+// RetryAttempt Information about a failed attempt, passed to RetryPolicy
+type RetryAttempt struct {
+	Attempt int           // Attempt number, 0 for the first request
+	Elapsed time.Duration // Time elapsed since the first attempt started
+	Err     error         // Error of the failed attempt
+}
+
+// RetryPolicy Retry policy, decides whether and when a failed request is retried
+//
+// Set a custom policy with WithRetryPolicy. The default policy is DefaultRetryPolicy.
+type RetryPolicy interface {
+	// ShouldRetry Decide whether the failed attempt should be retried
+	ShouldRetry(attempt RetryAttempt) bool
+	// NextDelay Return the delay before the next attempt
+	NextDelay(attempt RetryAttempt) time.Duration
+}
+
+// DefaultRetryPolicy Default retry policy
+//
+// Retries network errors, 429 and 5xx responses with exponential backoff and full
+// jitter. The server's Retry-After header takes precedence over the computed delay,
+// and the total time spent is capped by MaxElapsed. A Retry-After beyond the
+// MaxElapsed budget stops the retries, returning the last error.
+type DefaultRetryPolicy struct {
+	MaxRetries int           // Maximum retry count
+	BaseDelay  time.Duration // Base delay of the exponential backoff
+	MaxDelay   time.Duration // Maximum delay between two attempts
+	MaxElapsed time.Duration // Maximum total time, zero means unlimited
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewDefaultRetryPolicy Create default retry policy with the given maximum retry count
+func NewDefaultRetryPolicy(maxRetries int) *DefaultRetryPolicy {
+	return &DefaultRetryPolicy{
+		MaxRetries: maxRetries,
+		BaseDelay:  DefaultRetryBaseDelay,
+		MaxDelay:   DefaultRetryMaxDelay,
+		MaxElapsed: DefaultRetryMaxElapsed,
+	}
+}
+
+// ShouldRetry Decide whether the failed attempt should be retried
+func (p *DefaultRetryPolicy) ShouldRetry(attempt RetryAttempt) bool {
+	if attempt.Attempt >= p.MaxRetries {
+		return false
+	}
+	if p.MaxElapsed > 0 && attempt.Elapsed >= p.MaxElapsed {
+		return false
+	}
+	// Retrying before the server allows it would fail again
+	var apiErr *APIError
+	if p.MaxElapsed > 0 && errors.As(attempt.Err, &apiErr) && attempt.Elapsed+apiErr.RetryAfter > p.MaxElapsed {
+		return false
+	}
+	return IsRetryableError(attempt.Err)
+}
+
+// NextDelay Return the delay before the next attempt
+func (p *DefaultRetryPolicy) NextDelay(attempt RetryAttempt) time.Duration {
+	var delay time.Duration
+
+	var apiErr *APIError
+	if errors.As(attempt.Err, &apiErr) && apiErr.RetryAfter > 0 {
+		delay = apiErr.RetryAfter
+	} else {
+		// Full jitter: random delay in [0, min(MaxDelay, BaseDelay * 2^attempt)]
+		backoff := p.MaxDelay
+		if attempt.Attempt < 32 {
+			if d := p.BaseDelay << uint(attempt.Attempt); d > 0 && d < p.MaxDelay {
+				backoff = d
+			}
+		}
+		delay = p.jitter(backoff)
+	}
+
+	if p.MaxElapsed > 0 && attempt.Elapsed+delay > p.MaxElapsed {
+		delay = p.MaxElapsed - attempt.Elapsed
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// jitter Return a random duration in [0, max]
+func (p *DefaultRetryPolicy) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rand == nil {
+		p.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return time.Duration(p.rand.Int63n(int64(max) + 1))
+}
+
+// IsRetryableError Check if the error is transient: network error, rate limit or server error
+//
+// Context cancellation, deadline and open circuit errors are never retryable.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var networkErr *NetworkError
+	var rateLimitErr *RateLimitError
+	var serverErr *ServerError
+	return errors.As(err, &networkErr) || errors.As(err, &rateLimitErr) || errors.As(err, &serverErr)
+}
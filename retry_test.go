@@ -0,0 +1,73 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// fastRetries Retry policy with millisecond delays
+func fastRetries(maxRetries int) xiangxinai.Option {
+	return xiangxinai.WithRetryPolicy(&xiangxinai.DefaultRetryPolicy{
+		MaxRetries: maxRetries,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+	})
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, fastRetries(2))
+	server.Fail(xiangxintest.Fault{Status: http.StatusServiceUnavailable, Times: 2})
+
+	result, err := client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, xiangxinai.ActionPass, result.SuggestAction)
+	assert.Equal(t, 3, server.RequestCount())
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, fastRetries(2))
+	server.Fail(xiangxintest.Fault{Status: http.StatusBadGateway})
+
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	var serverErr *xiangxinai.ServerError
+	assert.True(t, errors.As(err, &serverErr), "got %v", err)
+	assert.Equal(t, 3, server.RequestCount())
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	client, server := xiangxintest.NewClient(t, fastRetries(2))
+	server.FailNext(http.StatusUnauthorized)
+
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	var authErr *xiangxinai.AuthenticationError
+	assert.True(t, errors.As(err, &authErr), "got %v", err)
+	assert.Equal(t, 1, server.RequestCount())
+}
+
+func TestRetryStopsWhenRetryAfterExceedsBudget(t *testing.T) {
+	policy := &xiangxinai.DefaultRetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxElapsed: 500 * time.Millisecond}
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithRetryPolicy(policy))
+	server.Fail(xiangxintest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 2 * time.Second})
+
+	start := time.Now()
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	var rateLimitErr *xiangxinai.RateLimitError
+	assert.True(t, errors.As(err, &rateLimitErr), "got %v", err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, 1, server.RequestCount())
+
+	// A Retry-After within the budget is honored
+	policy.MaxElapsed = 5 * time.Second
+	attempt := xiangxinai.RetryAttempt{Err: err, Elapsed: time.Second}
+	assert.True(t, policy.ShouldRetry(attempt))
+	assert.Equal(t, 2*time.Second, policy.NextDelay(attempt))
+}
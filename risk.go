@@ -0,0 +1,217 @@
+package xiangxinai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RiskLevel Risk level: no_risk, low_risk, medium_risk, high_risk
+//
+// Risk levels are ordered, use Compare or AtLeast instead of string comparison:
+//
+//	if result.OverallRiskLevel.AtLeast(xiangxinai.MediumRisk) {
+//		// ...
+//	}
+//
+// Unknown values returned by the API are kept as-is and reported by IsValid.
+// They rank above HighRisk, so threshold checks fail safe.
+type RiskLevel string
+
+// Risk levels
+const (
+	NoRisk     RiskLevel = "no_risk"
+	LowRisk    RiskLevel = "low_risk"
+	MediumRisk RiskLevel = "medium_risk"
+	HighRisk   RiskLevel = "high_risk"
+)
+
+// riskLevelRanks Ordering of known risk levels
+var riskLevelRanks = map[RiskLevel]int{
+	NoRisk:     0,
+	LowRisk:    1,
+	MediumRisk: 2,
+	HighRisk:   3,
+}
+
+// ParseRiskLevel Parse risk level, returning a ValidationError for unknown values
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	level := RiskLevel(strings.ToLower(strings.TrimSpace(s)))
+	if !level.IsValid() {
+		return "", NewValidationError(fmt.Sprintf("unknown risk level: %q", s))
+	}
+	return level, nil
+}
+
+// IsValid Check if the risk level is one of the known levels
+func (l RiskLevel) IsValid() bool {
+	_, ok := riskLevelRanks[l]
+	return ok
+}
+
+// String Return the risk level string
+func (l RiskLevel) String() string {
+	return string(l)
+}
+
+// rank Return the order of the risk level; empty ranks lowest, unknown highest
+func (l RiskLevel) rank() int {
+	if l == "" {
+		return -1
+	}
+	if rank, ok := riskLevelRanks[l]; ok {
+		return rank
+	}
+	return len(riskLevelRanks)
+}
+
+// Compare Compare two risk levels, returning -1, 0 or 1
+func (l RiskLevel) Compare(other RiskLevel) int {
+	switch a, b := l.rank(), other.rank(); {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeast Check if the risk level is at least the given level
+func (l RiskLevel) AtLeast(other RiskLevel) bool {
+	return l.Compare(other) >= 0
+}
+
+// UnmarshalJSON Unmarshal risk level, normalizing case and keeping unknown values
+func (l *RiskLevel) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("risk level must be a string: %w", err)
+	}
+	if s == nil {
+		*l = ""
+		return nil
+	}
+	*l = RiskLevel(strings.ToLower(strings.TrimSpace(*s)))
+	return nil
+}
+
+// MaxRiskLevel Return the highest of the given risk levels
+func MaxRiskLevel(levels ...RiskLevel) RiskLevel {
+	var max RiskLevel
+	for _, level := range levels {
+		if level.Compare(max) > 0 {
+			max = level
+		}
+	}
+	return max
+}
+
+// Action Suggested action: pass, reject, replace
+type Action string
+
+// Actions
+const (
+	ActionPass    Action = "pass"
+	ActionReject  Action = "reject"
+	ActionReplace Action = "replace"
+)
+
+// ParseAction Parse action, returning a ValidationError for unknown values
+func ParseAction(s string) (Action, error) {
+	action := Action(strings.ToLower(strings.TrimSpace(s)))
+	if !action.IsValid() {
+		return "", NewValidationError(fmt.Sprintf("unknown action: %q", s))
+	}
+	return action, nil
+}
+
+// IsValid Check if the action is one of the known actions
+func (a Action) IsValid() bool {
+	switch a {
+	case ActionPass, ActionReject, ActionReplace:
+		return true
+	default:
+		return false
+	}
+}
+
+// String Return the action string
+func (a Action) String() string {
+	return string(a)
+}
+
+// UnmarshalJSON Unmarshal action, normalizing case and keeping unknown values
+func (a *Action) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("action must be a string: %w", err)
+	}
+	if s == nil {
+		*a = ""
+		return nil
+	}
+	*a = Action(strings.ToLower(strings.TrimSpace(*s)))
+	return nil
+}
+
+// Dimension Detection dimension: compliance, security, data
+type Dimension string
+
+// Detection dimensions
+const (
+	DimensionCompliance Dimension = "compliance"
+	DimensionSecurity   Dimension = "security"
+	DimensionData       Dimension = "data"
+)
+
+// Dimensions All detection dimensions
+var Dimensions = []Dimension{DimensionCompliance, DimensionSecurity, DimensionData}
+
+// RiskFor Return the risk level of the given dimension, empty if not detected
+func (r *GuardrailResponse) RiskFor(dimension Dimension) RiskLevel {
+	level, _ := r.dimensionResult(dimension)
+	return level
+}
+
+// CategoriesFor Return the risk categories of the given dimension
+func (r *GuardrailResponse) CategoriesFor(dimension Dimension) []string {
+	_, categories := r.dimensionResult(dimension)
+	return categories
+}
+
+// MaxRiskLevel Return the highest risk level across all dimensions and the overall level
+func (r *GuardrailResponse) MaxRiskLevel() RiskLevel {
+	max := r.OverallRiskLevel
+	for _, dimension := range Dimensions {
+		max = MaxRiskLevel(max, r.RiskFor(dimension))
+	}
+	return max
+}
+
+// IsAtLeast Check if the highest risk level is at least the given level
+func (r *GuardrailResponse) IsAtLeast(level RiskLevel) bool {
+	return r.MaxRiskLevel().AtLeast(level)
+}
+
+// dimensionResult Return the risk level and categories of the given dimension
+func (r *GuardrailResponse) dimensionResult(dimension Dimension) (RiskLevel, []string) {
+	if r.Result == nil {
+		return "", nil
+	}
+	switch dimension {
+	case DimensionCompliance:
+		if r.Result.Compliance != nil {
+			return r.Result.Compliance.RiskLevel, r.Result.Compliance.Categories
+		}
+	case DimensionSecurity:
+		if r.Result.Security != nil {
+			return r.Result.Security.RiskLevel, r.Result.Security.Categories
+		}
+	case DimensionData:
+		if r.Result.Data != nil {
+			return r.Result.Data.RiskLevel, r.Result.Data.Categories
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,117 @@
+package xiangxinai_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+func TestRiskLevelCompare(t *testing.T) {
+	unknown := xiangxinai.RiskLevel("critical_risk")
+	tests := []struct {
+		a, b    xiangxinai.RiskLevel
+		compare int
+	}{
+		{xiangxinai.NoRisk, xiangxinai.NoRisk, 0},
+		{xiangxinai.NoRisk, xiangxinai.LowRisk, -1},
+		{xiangxinai.LowRisk, xiangxinai.MediumRisk, -1},
+		{xiangxinai.MediumRisk, xiangxinai.HighRisk, -1},
+		{xiangxinai.HighRisk, xiangxinai.NoRisk, 1},
+		{"", xiangxinai.NoRisk, -1}, // Empty ranks lowest
+		{"", "", 0},
+		{unknown, xiangxinai.HighRisk, 1}, // Unknown ranks highest
+		{unknown, xiangxinai.RiskLevel("other"), 0},
+		{xiangxinai.HighRisk, unknown, -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.compare, tt.a.Compare(tt.b), "%q vs %q", tt.a, tt.b)
+		assert.Equal(t, tt.compare >= 0, tt.a.AtLeast(tt.b), "%q at least %q", tt.a, tt.b)
+	}
+}
+
+func TestMaxRiskLevel(t *testing.T) {
+	tests := []struct {
+		levels []xiangxinai.RiskLevel
+		max    xiangxinai.RiskLevel
+	}{
+		{nil, ""},
+		{[]xiangxinai.RiskLevel{"", xiangxinai.NoRisk}, xiangxinai.NoRisk},
+		{[]xiangxinai.RiskLevel{xiangxinai.MediumRisk, xiangxinai.LowRisk}, xiangxinai.MediumRisk},
+		{[]xiangxinai.RiskLevel{xiangxinai.HighRisk, "severe"}, "severe"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.max, xiangxinai.MaxRiskLevel(tt.levels...), "%v", tt.levels)
+	}
+}
+
+func TestParseRiskLevel(t *testing.T) {
+	tests := []struct {
+		input string
+		level xiangxinai.RiskLevel
+		valid bool
+	}{
+		{"no_risk", xiangxinai.NoRisk, true},
+		{" High_Risk ", xiangxinai.HighRisk, true},
+		{"MEDIUM_RISK", xiangxinai.MediumRisk, true},
+		{"", "", false},
+		{"high", "", false},
+	}
+	for _, tt := range tests {
+		level, err := xiangxinai.ParseRiskLevel(tt.input)
+		if !tt.valid {
+			var validationErr *xiangxinai.ValidationError
+			assert.True(t, errors.As(err, &validationErr), "%q: got %v", tt.input, err)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.level, level)
+		assert.True(t, level.IsValid())
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		input  string
+		action xiangxinai.Action
+		valid  bool
+	}{
+		{"pass", xiangxinai.ActionPass, true},
+		{" Reject", xiangxinai.ActionReject, true},
+		{"REPLACE", xiangxinai.ActionReplace, true},
+		{"block", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		action, err := xiangxinai.ParseAction(tt.input)
+		if !tt.valid {
+			var validationErr *xiangxinai.ValidationError
+			assert.True(t, errors.As(err, &validationErr), "%q: got %v", tt.input, err)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.action, action)
+	}
+}
+
+func TestRiskUnmarshalKeepsUnknownValues(t *testing.T) {
+	var result struct {
+		Level  xiangxinai.RiskLevel `json:"level"`
+		Action xiangxinai.Action    `json:"action"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"level":"Critical_Risk","action":" Block "}`), &result))
+	assert.Equal(t, xiangxinai.RiskLevel("critical_risk"), result.Level)
+	assert.False(t, result.Level.IsValid())
+	assert.True(t, result.Level.AtLeast(xiangxinai.HighRisk))
+	assert.Equal(t, xiangxinai.Action("block"), result.Action)
+	assert.False(t, result.Action.IsValid())
+
+	require.NoError(t, json.Unmarshal([]byte(`{"level":null,"action":null}`), &result))
+	assert.Empty(t, result.Level)
+	assert.Empty(t, result.Action)
+	assert.Error(t, json.Unmarshal([]byte(`{"level":3}`), &result))
+}
@@ -0,0 +1,583 @@
+package xiangxinai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStreamCheckEveryChars Default number of new characters between two checks
+	DefaultStreamCheckEveryChars = 200
+	// DefaultStreamMinSentenceChars Default minimum number of new characters before a sentence boundary triggers a check
+	DefaultStreamMinSentenceChars = 20
+)
+
+// sentenceTerminators Characters that end a sentence
+const sentenceTerminators = ".!?;\n。！？；…"
+
+// StreamGuardConfig Stream guard configuration
+type StreamGuardConfig struct {
+	CheckEveryChars  int    // Check after this many new characters, default 200
+	SentenceBoundary bool   // Also check at sentence boundaries
+	MinSentenceChars int    // Minimum new characters before a sentence boundary triggers a check, default 20
+	WindowChars      int    // Only send the last WindowChars characters to each check, 0 sends all text
+	BlockedAnswer    string // Answer when a blocking result has no suggested answer, default DefaultBlockedAnswer
+}
+
+// StreamGuard Output moderation for streamed chat completions
+//
+// A StreamGuard accumulates the streamed answer and re-checks it against the
+// prompt through the /guardrails/output endpoint every CheckEveryChars
+// characters (and optionally at sentence boundaries). Checks run in the
+// background while the stream continues; at most one check is in flight. As
+// soon as a check returns reject or replace, the stream is stopped and the
+// suggested answer is emitted instead.
+//
+// Text already forwarded before the blocking check completed has reached the
+// consumer, so the consumer should replace the displayed answer when a
+// blocked chunk arrives.
+//
+// Example usage:
+//
+//	guard := client.NewStreamGuard(ctx, prompt, xiangxinai.StreamGuardConfig{SentenceBoundary: true})
+//	defer guard.Close()
+//	for chunk := range guard.Guard(ctx, deltas) {
+//		if chunk.Blocked {
+//			showReplacement(chunk.Delta)
+//			break
+//		}
+//		show(chunk.Delta)
+//	}
+type StreamGuard struct {
+	client *Client
+	prompt string
+	config StreamGuardConfig
+	userID []string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	text        []rune
+	checkedLen  int // Length of text covered by the last started check
+	inFlight    bool
+	pending     bool
+	result      *GuardrailResponse // Blocking result
+	lastResult  *GuardrailResponse // Last non-blocking result
+	err         error              // Last check error
+	blocked     chan struct{}
+	blockedOnce sync.Once
+	wg          sync.WaitGroup
+}
+
+// StreamChunk Chunk emitted by StreamGuard.Guard
+type StreamChunk struct {
+	Delta   string             // Text delta to forward, or the suggested answer if Blocked
+	Blocked bool               // The stream was stopped by the guardrail
+	Done    bool               // Last chunk of a stream that was not blocked
+	Result  *GuardrailResponse // Blocking result, or the final check result when Done
+	Err     error              // Error of the final check
+}
+
+// NewStreamGuard Create new stream guard for the answer to prompt
+//
+// In-flight checks are bound to ctx and cancelled by Close.
+func (c *Client) NewStreamGuard(ctx context.Context, prompt string, config StreamGuardConfig, userID ...string) *StreamGuard {
+	if config.CheckEveryChars <= 0 {
+		config.CheckEveryChars = DefaultStreamCheckEveryChars
+	}
+	if config.MinSentenceChars <= 0 {
+		config.MinSentenceChars = DefaultStreamMinSentenceChars
+	}
+	if config.BlockedAnswer == "" {
+		config.BlockedAnswer = DefaultBlockedAnswer
+	}
+	guardCtx, cancel := context.WithCancel(ctx)
+	return &StreamGuard{
+		client:  c,
+		prompt:  prompt,
+		config:  config,
+		userID:  userID,
+		ctx:     guardCtx,
+		cancel:  cancel,
+		blocked: make(chan struct{}),
+	}
+}
+
+// Append Add a text delta, starting a background check if the cadence is reached
+//
+// Returns false if the stream has been blocked; the delta must then not be forwarded.
+func (g *StreamGuard) Append(delta string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.result != nil {
+		return false
+	}
+	g.text = append(g.text, []rune(delta)...)
+
+	newChars := len(g.text) - g.checkedLen
+	due := newChars >= g.config.CheckEveryChars
+	if !due && g.config.SentenceBoundary && newChars >= g.config.MinSentenceChars {
+		due = strings.ContainsAny(delta, sentenceTerminators)
+	}
+	if due {
+		if g.inFlight {
+			g.pending = true
+		} else {
+			g.startCheckLocked()
+		}
+	}
+	return true
+}
+
+// Blocked Return a channel closed when a check blocks the stream
+func (g *StreamGuard) Blocked() <-chan struct{} {
+	return g.blocked
+}
+
+// Result Return the blocking result, nil if the stream is not blocked
+func (g *StreamGuard) Result() *GuardrailResponse {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.result
+}
+
+// Text Return the accumulated text
+func (g *StreamGuard) Text() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return string(g.text)
+}
+
+// Finish Wait for in-flight checks and check the remaining text
+//
+// Returns the blocking result if the stream was blocked, otherwise the result of
+// the last check.
+func (g *StreamGuard) Finish() (*GuardrailResponse, error) {
+	g.wg.Wait()
+
+	g.mu.Lock()
+	if g.result != nil {
+		result := g.result
+		g.mu.Unlock()
+		return result, nil
+	}
+	if g.checkedLen == len(g.text) && g.lastResult != nil {
+		result, err := g.lastResult, g.err
+		g.mu.Unlock()
+		return result, err
+	}
+	snapshot := g.snapshotLocked()
+	g.checkedLen = len(g.text)
+	g.mu.Unlock()
+
+	result, err := g.check(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if g.isBlocking(result) {
+		g.block(result)
+	}
+	return result, nil
+}
+
+// Close Cancel in-flight checks
+func (g *StreamGuard) Close() error {
+	g.cancel()
+	return nil
+}
+
+// Guard Guard a channel of text deltas
+//
+// Deltas are forwarded as they arrive. When a check blocks the stream, a
+// chunk with Blocked set and the suggested answer is emitted, the output
+// channel is closed and deltas is no longer read, so producers should also
+// watch ctx. When deltas is closed, the remaining text is checked and a final
+// chunk with Done (or Blocked) set is emitted.
+func (g *StreamGuard) Guard(ctx context.Context, deltas <-chan string) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+
+	go func() {
+		defer close(out)
+		defer g.Close()
+
+		send := func(chunk StreamChunk) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-g.blocked:
+				send(g.blockedChunk())
+				return
+			case delta, ok := <-deltas:
+				if !ok {
+					result, err := g.Finish()
+					if g.Result() != nil {
+						send(g.blockedChunk())
+					} else {
+						send(StreamChunk{Done: true, Result: result, Err: err})
+					}
+					return
+				}
+				if !g.Append(delta) {
+					send(g.blockedChunk())
+					return
+				}
+				if !send(StreamChunk{Delta: delta}) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// GuardSSE Guard an OpenAI style SSE stream of chat completion chunks
+//
+// The returned reader yields the original events. Completions with several
+// choices are checked per choice index; the guard itself holds the text of
+// choice 0. When a check blocks the stream, it yields a chunk whose delta is
+// the suggested answer with finish_reason "content_filter" for every choice,
+// followed by "data: [DONE]", and stops reading r. Closing the returned reader cancels in-flight checks and closes r
+// if it is an io.Closer.
+func (g *StreamGuard) GuardSSE(ctx context.Context, r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	sse := &sseGuard{guard: g, r: r, pr: pr, choices: map[int]*StreamGuard{0: g}}
+
+	go func() {
+		err := sse.run(ctx, pw)
+		g.Close()
+		pw.CloseWithError(err)
+	}()
+
+	return sse
+}
+
+// blockedChunk Return the chunk emitted when the stream is blocked
+func (g *StreamGuard) blockedChunk() StreamChunk {
+	result := g.Result()
+	return StreamChunk{Delta: g.answer(result), Blocked: true, Result: result}
+}
+
+// answer Return the suggested answer of a blocking result
+func (g *StreamGuard) answer(result *GuardrailResponse) string {
+	if result == nil {
+		return g.config.BlockedAnswer
+	}
+	return result.FinalAnswer(g.config.BlockedAnswer)
+}
+
+// isBlocking Check if a result stops the stream
+func (g *StreamGuard) isBlocking(result *GuardrailResponse) bool {
+	action := result.FinalAction()
+	return action == ActionReject || action == ActionReplace
+}
+
+// startCheckLocked Start a background check of the current text, must be called with mu held
+func (g *StreamGuard) startCheckLocked() {
+	snapshot := g.snapshotLocked()
+	g.checkedLen = len(g.text)
+	g.inFlight = true
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+		result, err := g.check(snapshot)
+
+		g.mu.Lock()
+		g.inFlight = false
+		if err != nil {
+			if g.ctx.Err() == nil {
+				g.err = err
+			}
+		} else if g.isBlocking(result) {
+			g.mu.Unlock()
+			g.block(result)
+			return
+		} else {
+			g.lastResult = result
+			g.err = nil
+		}
+		if g.pending && g.result == nil && g.ctx.Err() == nil {
+			g.pending = false
+			g.startCheckLocked()
+		}
+		g.mu.Unlock()
+	}()
+}
+
+// snapshotLocked Return the text to check, limited to the window, must be called with mu held
+func (g *StreamGuard) snapshotLocked() string {
+	text := g.text
+	if g.config.WindowChars > 0 && len(text) > g.config.WindowChars {
+		text = text[len(text)-g.config.WindowChars:]
+	}
+	return string(text)
+}
+
+// check Check text against the prompt
+func (g *StreamGuard) check(text string) (*GuardrailResponse, error) {
+	return g.client.CheckResponseCtx(g.ctx, g.prompt, text, g.userID...)
+}
+
+// block Record the blocking result and cancel other checks
+func (g *StreamGuard) block(result *GuardrailResponse) {
+	g.blockedOnce.Do(func() {
+		g.mu.Lock()
+		g.result = result
+		g.pending = false
+		g.mu.Unlock()
+		close(g.blocked)
+		g.cancel()
+	})
+}
+
+// sseGuard Reader returned by GuardSSE
+type sseGuard struct {
+	guard   *StreamGuard
+	r       io.Reader
+	pr      *io.PipeReader
+	choices map[int]*StreamGuard // Guard of each choice index, guard for choice 0
+
+	id      string
+	model   string
+	created int64
+}
+
+// Read Read guarded SSE output
+func (s *sseGuard) Read(p []byte) (int, error) {
+	return s.pr.Read(p)
+}
+
+// Close Stop guarding, cancelling in-flight checks and closing the source
+func (s *sseGuard) Close() error {
+	s.guard.Close()
+	s.pr.Close()
+	if closer, ok := s.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// sseChunk Fields of a chat completion chunk used by the guard
+type sseChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// sseEvent SSE event read from the source, or the error that ended the source
+type sseEvent struct {
+	data []byte
+	err  error
+}
+
+// run Copy events from the source to w, stopping as soon as the guard blocks
+//
+// Events are read in a separate goroutine, so a check that blocks while the
+// source is idle stops the stream immediately.
+func (s *sseGuard) run(ctx context.Context, w io.Writer) error {
+	events := make(chan sseEvent)
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.readEvents(events, stop)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.guard.Blocked():
+			return s.writeBlocked(w)
+		case event := <-events:
+			if event.err == io.EOF {
+				return s.finish(w)
+			}
+			if event.err != nil {
+				return event.err
+			}
+			done, err := s.handleEvent(w, event.data)
+			if err != nil || done {
+				return err
+			}
+		}
+	}
+}
+
+// readEvents Read events from the source until it ends or stop is closed
+//
+// A read in progress when stop is closed ends when the source is closed.
+func (s *sseGuard) readEvents(events chan<- sseEvent, stop <-chan struct{}) {
+	emit := func(event sseEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	reader := bufio.NewReaderSize(s.r, 64*1024)
+	var event bytes.Buffer
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			event.Write(line)
+			if len(bytes.TrimSpace(line)) == 0 {
+				if !emit(sseEvent{data: append([]byte(nil), event.Bytes()...)}) {
+					return
+				}
+				event.Reset()
+			}
+		}
+		if err != nil {
+			if err == io.EOF && event.Len() > 0 {
+				if !emit(sseEvent{data: append(event.Bytes(), '\n')}) {
+					return
+				}
+			}
+			emit(sseEvent{err: err})
+			return
+		}
+	}
+}
+
+// handleEvent Process a single SSE event, returning true when the stream is done
+func (s *sseGuard) handleEvent(w io.Writer, event []byte) (bool, error) {
+	select {
+	case <-s.guard.Blocked():
+		return true, s.writeBlocked(w)
+	default:
+	}
+
+	data := sseData(event)
+	if data == "[DONE]" {
+		return true, s.finish(w)
+	}
+
+	if data != "" {
+		var chunk sseChunk
+		if json.Unmarshal([]byte(data), &chunk) == nil {
+			if chunk.ID != "" {
+				s.id, s.model, s.created = chunk.ID, chunk.Model, chunk.Created
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content != "" && !s.choice(choice.Index).Append(choice.Delta.Content) {
+					return true, s.writeBlocked(w)
+				}
+			}
+		}
+	}
+
+	_, err := w.Write(event)
+	return false, err
+}
+
+// choice Return the guard of a choice index, creating it if needed
+//
+// A blocking check of any choice blocks the whole stream.
+func (s *sseGuard) choice(index int) *StreamGuard {
+	if guard, ok := s.choices[index]; ok {
+		return guard
+	}
+	g := s.guard
+	guard := g.client.NewStreamGuard(g.ctx, g.prompt, g.config, g.userID...)
+	s.choices[index] = guard
+	go func() {
+		select {
+		case <-guard.Blocked():
+			g.block(guard.Result())
+		case <-g.ctx.Done():
+		}
+	}()
+	return guard
+}
+
+// finish Check the remaining text of every choice and terminate the stream
+func (s *sseGuard) finish(w io.Writer) error {
+	for _, guard := range s.choices {
+		result, err := guard.Finish()
+		if err != nil {
+			return err
+		}
+		if guard.Result() != nil {
+			s.guard.block(result)
+			break
+		}
+	}
+	if s.guard.Result() != nil {
+		return s.writeBlocked(w)
+	}
+	_, err := io.WriteString(w, "data: [DONE]\n\n")
+	return err
+}
+
+// writeBlocked Write the suggested answer chunk and terminate the stream
+func (s *sseGuard) writeBlocked(w io.Writer) error {
+	created := s.created
+	if created == 0 {
+		created = time.Now().Unix()
+	}
+	indexes := make([]int, 0, len(s.choices))
+	for index := range s.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	answer := s.guard.answer(s.guard.Result())
+	choices := make([]map[string]interface{}, 0, len(indexes))
+	for _, index := range indexes {
+		choices = append(choices, map[string]interface{}{
+			"index":         index,
+			"delta":         map[string]interface{}{"content": answer},
+			"finish_reason": "content_filter",
+		})
+	}
+	chunk := map[string]interface{}{
+		"id":      s.id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   s.model,
+		"choices": choices,
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", data)
+	return err
+}
+
+// sseData Return the joined data lines of an SSE event
+func sseData(event []byte) string {
+	var lines []string
+	for _, line := range strings.Split(string(event), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "data:") {
+			lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,224 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestStreamGuardCheckEveryChars(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	guard := client.NewStreamGuard(context.Background(), "prompt", xiangxinai.StreamGuardConfig{CheckEveryChars: 10})
+	defer guard.Close()
+
+	assert.True(t, guard.Append("0123456789")) // Reaches the cadence
+	assert.True(t, guard.Append("abcde"))      // 5 new characters, no check
+	result, err := guard.Finish()              // Checks the remaining text
+	require.NoError(t, err)
+	assert.Equal(t, xiangxinai.ActionPass, result.SuggestAction)
+
+	requests := server.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "0123456789", requests[0].Output)
+	assert.Equal(t, "0123456789abcde", requests[1].Output)
+	assert.Equal(t, "prompt", requests[1].Input)
+}
+
+func TestStreamGuardSentenceBoundary(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	guard := client.NewStreamGuard(context.Background(), "prompt", xiangxinai.StreamGuardConfig{
+		CheckEveryChars:  1000,
+		SentenceBoundary: true,
+		MinSentenceChars: 5,
+	})
+	defer guard.Close()
+
+	guard.Append("Hi.")     // Too few characters for a sentence check
+	guard.Append(" there.") // Sentence boundary after 10 characters
+	_, err := guard.Finish()
+	require.NoError(t, err)
+
+	requests := server.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "Hi. there.", requests[0].Output)
+}
+
+func TestStreamGuardWindow(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	guard := client.NewStreamGuard(context.Background(), "prompt", xiangxinai.StreamGuardConfig{
+		CheckEveryChars: 10,
+		WindowChars:     4,
+	})
+	defer guard.Close()
+
+	guard.Append("你好世界，今天天气很好")
+	_, err := guard.Finish()
+	require.NoError(t, err)
+
+	for _, req := range server.Requests() {
+		assert.Equal(t, "天气很好", req.Output)
+	}
+	assert.Equal(t, "你好世界，今天天气很好", guard.Text())
+}
+
+func TestStreamGuardBlocks(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.OnCategory(xiangxinai.DimensionCompliance, "violence", `forbidden`)
+
+	ctx := context.Background()
+	guard := client.NewStreamGuard(ctx, "prompt", xiangxinai.StreamGuardConfig{CheckEveryChars: 5})
+	deltas := make(chan string)
+	out := guard.Guard(ctx, deltas)
+
+	deltas <- "some forbidden text"
+	chunk := <-out
+	assert.Equal(t, "some forbidden text", chunk.Delta)
+
+	select {
+	case chunk = <-out:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not blocked")
+	}
+	assert.True(t, chunk.Blocked)
+	assert.Equal(t, xiangxintest.DefaultAnswer, chunk.Delta)
+	require.NotNil(t, chunk.Result)
+	assert.Equal(t, xiangxinai.ActionReject, chunk.Result.SuggestAction)
+
+	_, ok := <-out
+	assert.False(t, ok)
+	assert.False(t, guard.Append("more"))
+}
+
+func TestGuardSSEBlocksWhileUpstreamIdle(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.OnCategory(xiangxinai.DimensionCompliance, "violence", `forbidden`)
+
+	ctx := context.Background()
+	upstream, upstreamWriter := io.Pipe()
+	defer upstreamWriter.Close()
+
+	guard := client.NewStreamGuard(ctx, "prompt", xiangxinai.StreamGuardConfig{CheckEveryChars: 5})
+	stream := guard.GuardSSE(ctx, upstream)
+	defer stream.Close()
+
+	// One event, then the upstream stays idle
+	go io.WriteString(upstreamWriter, `data: {"id":"chatcmpl-1","model":"gpt","created":1,"choices":[{"delta":{"content":"forbidden words"}}]}`+"\n\n")
+
+	output := make(chan string, 1)
+	go func() {
+		data, _ := io.ReadAll(stream)
+		output <- string(data)
+	}()
+
+	select {
+	case data := <-output:
+		events := strings.Split(strings.TrimSpace(data), "\n\n")
+		require.Len(t, events, 3)
+		assert.Contains(t, events[0], "forbidden words")
+		assert.Contains(t, events[1], `"finish_reason":"content_filter"`)
+		assert.Contains(t, events[1], `"id":"chatcmpl-1"`)
+		assert.Contains(t, events[1], xiangxintest.DefaultAnswer)
+		assert.Equal(t, "data: [DONE]", events[2])
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not stopped while the upstream was idle")
+	}
+}
+
+func TestGuardSSEPassesStream(t *testing.T) {
+	client, _ := xiangxintest.NewClient(t)
+	ctx := context.Background()
+	source := `data: {"choices":[{"delta":{"content":"Hello"}}]}` + "\n\n" +
+		`data: {"choices":[{"delta":{"content":" world"}}]}` + "\n\n" +
+		"data: [DONE]\n\n"
+
+	guard := client.NewStreamGuard(ctx, "prompt", xiangxinai.StreamGuardConfig{CheckEveryChars: 5})
+	stream := guard.GuardSSE(ctx, strings.NewReader(source))
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, source, string(data))
+	assert.Equal(t, "Hello world", guard.Text())
+}
+
+func TestGuardSSEChecksChoicesSeparately(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.OnCategory(xiangxinai.DimensionCompliance, "violence", `forbidden`)
+	ctx := context.Background()
+	source := `data: {"choices":[{"index":0,"delta":{"content":"forb"}}]}` + "\n\n" +
+		`data: {"choices":[{"index":1,"delta":{"content":"idden"}}]}` + "\n\n" +
+		"data: [DONE]\n\n"
+
+	guard := client.NewStreamGuard(ctx, "prompt", xiangxinai.StreamGuardConfig{CheckEveryChars: 100})
+	stream := guard.GuardSSE(ctx, strings.NewReader(source))
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, source, string(data))
+	assert.Equal(t, "forb", guard.Text())
+
+	var outputs []string
+	for _, req := range server.Requests() {
+		outputs = append(outputs, req.Output)
+	}
+	assert.ElementsMatch(t, []string{"forb", "idden"}, outputs)
+}
+
+func TestGuardSSEBlocksOnAnyChoice(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.OnCategory(xiangxinai.DimensionCompliance, "violence", `forbidden`)
+	ctx := context.Background()
+	source := `data: {"choices":[{"index":0,"delta":{"content":"fine"}},{"index":1,"delta":{"content":"forbi"}}]}` + "\n\n" +
+		`data: {"choices":[{"index":1,"delta":{"content":"dden"}}]}` + "\n\n" +
+		"data: [DONE]\n\n"
+
+	guard := client.NewStreamGuard(ctx, "prompt", xiangxinai.StreamGuardConfig{CheckEveryChars: 100})
+	stream := guard.GuardSSE(ctx, strings.NewReader(source))
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	events := strings.Split(strings.TrimSpace(string(data)), "\n\n")
+	require.Len(t, events, 4)
+	assert.Contains(t, events[2], `"index":0`)
+	assert.Contains(t, events[2], `"index":1`)
+	assert.Equal(t, 2, strings.Count(events[2], `"finish_reason":"content_filter"`))
+	require.NotNil(t, guard.Result())
+	assert.Equal(t, xiangxinai.ActionReject, guard.Result().SuggestAction)
+}
+
+func TestStreamGuardFallbackAnswer(t *testing.T) {
+	// The policy rejects a response the server passes, without an answer
+	client, server := xiangxintest.NewClient(t, xiangxinai.WithPolicy(&xiangxinai.Policy{
+		Rules: []xiangxinai.PolicyRule{{Dimension: xiangxinai.DimensionCompliance, Action: xiangxinai.ActionReject}},
+	}))
+	server.OnContent(`rumor`, xiangxintest.Verdict{Categories: []string{"rumor"}, RiskLevel: xiangxinai.LowRisk, Action: xiangxinai.ActionPass})
+
+	for _, tc := range []struct {
+		config xiangxinai.StreamGuardConfig
+		answer string
+	}{
+		{xiangxinai.StreamGuardConfig{CheckEveryChars: 5}, xiangxinai.DefaultBlockedAnswer},
+		{xiangxinai.StreamGuardConfig{CheckEveryChars: 5, BlockedAnswer: "Blocked."}, "Blocked."},
+	} {
+		guard := client.NewStreamGuard(context.Background(), "prompt", tc.config)
+		deltas := make(chan string, 1)
+		deltas <- "a rumor"
+		close(deltas)
+		var last xiangxinai.StreamChunk
+		for chunk := range guard.Guard(context.Background(), deltas) {
+			last = chunk
+		}
+		assert.True(t, last.Blocked)
+		assert.Equal(t, tc.answer, last.Delta)
+	}
+}
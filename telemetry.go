@@ -0,0 +1,293 @@
+package xiangxinai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName Instrumentation scope name of the client's spans and metrics
+const InstrumentationName = "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+
+// Span and metric attribute keys
+const (
+	AttrEndpoint   = attribute.Key("xiangxinai.endpoint")
+	AttrModel      = attribute.Key("xiangxinai.model")
+	AttrAttempts   = attribute.Key("xiangxinai.attempts")
+	AttrRiskLevel  = attribute.Key("xiangxinai.risk_level")
+	AttrAction     = attribute.Key("xiangxinai.action")
+	AttrCategories = attribute.Key("xiangxinai.categories")
+	AttrCategory   = attribute.Key("xiangxinai.category")
+	AttrCached     = attribute.Key("xiangxinai.cached")
+	AttrDegraded   = attribute.Key("xiangxinai.degraded")
+	AttrOutcome    = attribute.Key("xiangxinai.outcome")
+	AttrBatchSize  = attribute.Key("xiangxinai.batch_size")
+	AttrErrorType  = attribute.Key("error.type")
+)
+
+// TelemetryConfig OpenTelemetry instrumentation settings, used with WithTelemetry
+//
+// Every request of a Check* call gets a client span named "xiangxinai.check"
+// with the endpoint, model, attempt count, risk level, action and categories as
+// attributes. The client records these metrics:
+//
+//   - xiangxinai.client.request.duration: Check* latency histogram in seconds, by endpoint, model and outcome
+//   - xiangxinai.client.retries: retried attempts, by endpoint and error type
+//   - xiangxinai.client.errors: failed Check* calls, by endpoint and error type
+//   - xiangxinai.client.decisions: results, by endpoint, action and risk level
+//   - xiangxinai.client.categories: detected risk categories, by endpoint, category and action
+type TelemetryConfig struct {
+	TracerProvider trace.TracerProvider // Tracer provider, nil uses the global provider
+	MeterProvider  metric.MeterProvider // Meter provider, nil uses the global provider
+}
+
+// telemetry Tracer and metric instruments of a client
+type telemetry struct {
+	tracer     trace.Tracer
+	duration   metric.Float64Histogram
+	retries    metric.Int64Counter
+	errors     metric.Int64Counter
+	decisions  metric.Int64Counter
+	categories metric.Int64Counter
+}
+
+// newTelemetry Create the tracer and metric instruments
+func newTelemetry(config TelemetryConfig) (*telemetry, error) {
+	tracerProvider := config.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	meterProvider := config.MeterProvider
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+
+	version := strings.TrimPrefix(UserAgent, "xiangxinai-go/")
+	meter := meterProvider.Meter(InstrumentationName, metric.WithInstrumentationVersion(version))
+	t := &telemetry{
+		tracer: tracerProvider.Tracer(InstrumentationName, trace.WithInstrumentationVersion(version)),
+	}
+
+	var err error
+	if t.duration, err = meter.Float64Histogram("xiangxinai.client.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of guardrail checks")); err != nil {
+		return nil, NewXiangxinAIError("failed to create metric instrument", err)
+	}
+	if t.retries, err = meter.Int64Counter("xiangxinai.client.retries",
+		metric.WithUnit("{retry}"), metric.WithDescription("Retried guardrail request attempts")); err != nil {
+		return nil, NewXiangxinAIError("failed to create metric instrument", err)
+	}
+	if t.errors, err = meter.Int64Counter("xiangxinai.client.errors",
+		metric.WithUnit("{error}"), metric.WithDescription("Failed guardrail checks")); err != nil {
+		return nil, NewXiangxinAIError("failed to create metric instrument", err)
+	}
+	if t.decisions, err = meter.Int64Counter("xiangxinai.client.decisions",
+		metric.WithUnit("{decision}"), metric.WithDescription("Guardrail check results by action")); err != nil {
+		return nil, NewXiangxinAIError("failed to create metric instrument", err)
+	}
+	if t.categories, err = meter.Int64Counter("xiangxinai.client.categories",
+		metric.WithUnit("{detection}"), metric.WithDescription("Detected risk categories")); err != nil {
+		return nil, NewXiangxinAIError("failed to create metric instrument", err)
+	}
+	return t, nil
+}
+
+// callStatsContextKey Context key of the statistics of the current check
+type callStatsContextKey struct{}
+
+// callStats Statistics of a check, updated by the request path
+type callStats struct {
+	attempts int32 // HTTP attempts, updated atomically
+	err      error // Error that caused a degraded response
+}
+
+// withCallStats Return a copy of ctx carrying new statistics of a check
+func withCallStats(ctx context.Context) (context.Context, *callStats) {
+	stats := &callStats{}
+	return context.WithValue(ctx, callStatsContextKey{}, stats), stats
+}
+
+// attemptCount Return the number of HTTP attempts so far
+func (s *callStats) attemptCount() int {
+	return int(atomic.LoadInt32(&s.attempts))
+}
+
+// addAttempt Count an HTTP attempt of the check in ctx
+func addAttempt(ctx context.Context) {
+	addAttempts(ctx, 1)
+}
+
+// addAttempts Count n HTTP attempts of the check in ctx
+func addAttempts(ctx context.Context, n int) {
+	if stats, ok := ctx.Value(callStatsContextKey{}).(*callStats); ok && n > 0 {
+		atomic.AddInt32(&stats.attempts, int32(n))
+	}
+}
+
+// setDegradedCause Record the error replaced by a degraded response of the check in ctx
+func setDegradedCause(ctx context.Context, err error) {
+	if stats, ok := ctx.Value(callStatsContextKey{}).(*callStats); ok {
+		stats.err = err
+	}
+}
+
+// checkCall Span and statistics of a running check
+type checkCall struct {
+	t        *telemetry
+	span     trace.Span
+	stats    *callStats
+	start    time.Time
+	endpoint string
+	model    string
+}
+
+// start Start the span of a check; the returned context carries the span
+func (t *telemetry) start(ctx context.Context, endpoint, model string, stats *callStats) (context.Context, *checkCall) {
+	attrs := []attribute.KeyValue{AttrEndpoint.String(endpoint)}
+	if model != "" {
+		attrs = append(attrs, AttrModel.String(model))
+	}
+	ctx, span := t.tracer.Start(ctx, "xiangxinai.check", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	return ctx, &checkCall{t: t, span: span, stats: stats, start: time.Now(), endpoint: endpoint, model: model}
+}
+
+// end End the span and record the metrics of a finished check
+func (call *checkCall) end(ctx context.Context, resp *GuardrailResponse, err error) {
+	outcome := call.record(ctx, resp, err)
+	if err != nil {
+		call.span.RecordError(err)
+		call.span.SetStatus(codes.Error, err.Error())
+		call.span.SetAttributes(AttrErrorType.String(errorTypeOf(err)))
+	} else {
+		call.span.SetAttributes(
+			AttrRiskLevel.String(string(resp.OverallRiskLevel)),
+			AttrAction.String(string(resp.FinalAction())),
+			AttrCategories.StringSlice(resp.GetAllCategories()),
+		)
+		switch outcome {
+		case "degraded":
+			call.span.SetAttributes(AttrDegraded.Bool(true), AttrErrorType.String(errorTypeOf(call.stats.err)))
+		case "cached":
+			call.span.SetAttributes(AttrCached.Bool(true))
+		}
+	}
+	call.finish(ctx, outcome)
+}
+
+// endBatch End the span of a batch request and record the metrics of each item
+func (call *checkCall) endBatch(ctx context.Context, resp *GuardrailResponse, err error) {
+	outcome := "success"
+	switch {
+	case errors.Is(err, ErrBatchUnsupported):
+		outcome = "unsupported"
+	case err != nil:
+		outcome = call.record(ctx, nil, err)
+		call.span.RecordError(err)
+		call.span.SetStatus(codes.Error, err.Error())
+		call.span.SetAttributes(AttrErrorType.String(errorTypeOf(err)))
+	default:
+		for _, item := range resp.batchResults() {
+			call.record(ctx, item.Result, item.Err)
+		}
+	}
+	call.finish(ctx, outcome)
+}
+
+// record Record the error or decision metrics of a check result, returning its outcome
+func (call *checkCall) record(ctx context.Context, resp *GuardrailResponse, err error) string {
+	t := call.t
+	endpoint := AttrEndpoint.String(call.endpoint)
+	switch {
+	case err != nil:
+		t.errors.Add(ctx, 1, metric.WithAttributes(endpoint, AttrErrorType.String(errorTypeOf(err))))
+		return "error"
+	case resp.Degraded:
+		t.errors.Add(ctx, 1, metric.WithAttributes(endpoint, AttrErrorType.String(errorTypeOf(call.stats.err))))
+		return "degraded"
+	}
+
+	action := AttrAction.String(string(resp.FinalAction()))
+	t.decisions.Add(ctx, 1, metric.WithAttributes(endpoint, action, AttrRiskLevel.String(string(resp.OverallRiskLevel))))
+	for _, category := range resp.GetAllCategories() {
+		t.categories.Add(ctx, 1, metric.WithAttributes(endpoint, AttrCategory.String(category), action))
+	}
+	if resp.Cached {
+		return "cached"
+	}
+	return "success"
+}
+
+// finish Record the attempt count and latency, and end the span
+func (call *checkCall) finish(ctx context.Context, outcome string) {
+	call.span.SetAttributes(AttrAttempts.Int(call.stats.attemptCount()))
+
+	attrs := []attribute.KeyValue{AttrEndpoint.String(call.endpoint), AttrOutcome.String(outcome)}
+	if call.model != "" {
+		attrs = append(attrs, AttrModel.String(call.model))
+	}
+	call.t.duration.Record(ctx, time.Since(call.start).Seconds(), metric.WithAttributes(attrs...))
+	call.span.End()
+}
+
+// recordRetry Record a retried attempt on the current span and in the retry metric
+func (t *telemetry) recordRetry(ctx context.Context, endpoint string, attempt RetryAttempt, delay time.Duration) {
+	errorType := errorTypeOf(attempt.Err)
+	trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+		attribute.Int("xiangxinai.attempt", attempt.Attempt+1),
+		AttrErrorType.String(errorType),
+		attribute.Float64("xiangxinai.retry_delay_seconds", delay.Seconds()),
+	))
+	t.retries.Add(ctx, 1, metric.WithAttributes(AttrEndpoint.String(endpoint), AttrErrorType.String(errorType)))
+}
+
+// errorTypeOf Return the low-cardinality type of an error, used as the error.type attribute
+func errorTypeOf(err error) string {
+	var authErr *AuthenticationError
+	var rateLimitErr *RateLimitError
+	var validationErr *ValidationError
+	var serverErr *ServerError
+	var networkErr *NetworkError
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &authErr):
+		return "authentication"
+	case errors.As(err, &rateLimitErr):
+		return "rate_limit"
+	case errors.As(err, &validationErr):
+		return "validation"
+	case errors.As(err, &serverErr):
+		return "server"
+	case errors.As(err, &networkErr):
+		return "network"
+	case errors.As(err, &apiErr):
+		return "api"
+	default:
+		return "other"
+	}
+}
+
+// requestModel Return the model of a check request, empty if not set
+func requestModel(requestData interface{}) string {
+	switch data := requestData.(type) {
+	case *GuardrailRequest:
+		return data.Model
+	}
+	return ""
+}
@@ -0,0 +1,202 @@
+package xiangxinai_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+// telemetryRecorder In-memory span recorder and metric reader of a client
+type telemetryRecorder struct {
+	spans  *tracetest.SpanRecorder
+	reader sdkmetric.Reader
+}
+
+// newTelemetryClient Create a client of a mock server recording its telemetry in memory
+func newTelemetryClient(t *testing.T, opts ...xiangxinai.Option) (*xiangxinai.Client, *xiangxintest.Server, *telemetryRecorder) {
+	t.Helper()
+	recorder := &telemetryRecorder{spans: tracetest.NewSpanRecorder(), reader: sdkmetric.NewManualReader()}
+	opts = append(opts, xiangxinai.WithTelemetry(xiangxinai.TelemetryConfig{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder.spans)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(recorder.reader)),
+	}))
+	client, server := xiangxintest.NewClient(t, opts...)
+	return client, server, recorder
+}
+
+// span Return the only ended span
+func (r *telemetryRecorder) span(t *testing.T) sdktrace.ReadOnlySpan {
+	t.Helper()
+	spans := r.spans.Ended()
+	require.Len(t, spans, 1)
+	return spans[0]
+}
+
+// collect Collect the metrics, keyed by instrument name
+func (r *telemetryRecorder) collect(t *testing.T) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, r.reader.Collect(context.Background(), &rm))
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, scope := range rm.ScopeMetrics {
+		assert.Equal(t, xiangxinai.InstrumentationName, scope.Scope.Name)
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+// hasAttributes Report whether set contains all attrs
+func hasAttributes(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if value, ok := set.Value(attr.Key); !ok || value != attr.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// counterValue Return the sum of the counter data points with all attrs
+func counterValue(t *testing.T, metrics map[string]metricdata.Aggregation, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	sum, ok := metrics[name].(metricdata.Sum[int64])
+	require.True(t, ok, "no counter %s", name)
+	var value int64
+	for _, point := range sum.DataPoints {
+		if hasAttributes(point.Attributes, attrs) {
+			value += point.Value
+		}
+	}
+	return value
+}
+
+// histogramCount Return the number of measurements of the histogram data points with all attrs
+func histogramCount(t *testing.T, metrics map[string]metricdata.Aggregation, name string, attrs ...attribute.KeyValue) uint64 {
+	t.Helper()
+	histogram, ok := metrics[name].(metricdata.Histogram[float64])
+	require.True(t, ok, "no histogram %s", name)
+	var count uint64
+	for _, point := range histogram.DataPoints {
+		if hasAttributes(point.Attributes, attrs) {
+			count += point.Count
+		}
+	}
+	return count
+}
+
+// spanAttributes Return the attributes of a span
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func TestTelemetryWithoutLogger(t *testing.T) {
+	client, _, recorder := newTelemetryClient(t)
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "xiangxinai.check", recorder.span(t).Name())
+}
+
+func TestTelemetryRecordsDecision(t *testing.T) {
+	client, server, recorder := newTelemetryClient(t)
+	server.OnCategory(xiangxinai.DimensionCompliance, "violence", `forbidden`)
+
+	_, err := client.CheckConversation(context.Background(), []*xiangxinai.Message{xiangxinai.NewMessage("user", "forbidden words")})
+	require.NoError(t, err)
+
+	span := recorder.span(t)
+	assert.Equal(t, "xiangxinai.check", span.Name())
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+	assert.Equal(t, codes.Unset, span.Status().Code)
+	attrs := spanAttributes(span)
+	assert.Equal(t, "/guardrails", attrs[xiangxinai.AttrEndpoint].AsString())
+	assert.Equal(t, xiangxinai.DefaultModel, attrs[xiangxinai.AttrModel].AsString())
+	assert.Equal(t, "high_risk", attrs[xiangxinai.AttrRiskLevel].AsString())
+	assert.Equal(t, "reject", attrs[xiangxinai.AttrAction].AsString())
+	assert.Equal(t, []string{"violence"}, attrs[xiangxinai.AttrCategories].AsStringSlice())
+	assert.Equal(t, int64(1), attrs[xiangxinai.AttrAttempts].AsInt64())
+
+	metrics := recorder.collect(t)
+	endpoint := xiangxinai.AttrEndpoint.String("/guardrails")
+	assert.Equal(t, int64(1), counterValue(t, metrics, "xiangxinai.client.decisions",
+		endpoint, xiangxinai.AttrAction.String("reject"), xiangxinai.AttrRiskLevel.String("high_risk")))
+	assert.Equal(t, int64(1), counterValue(t, metrics, "xiangxinai.client.categories",
+		endpoint, xiangxinai.AttrCategory.String("violence"), xiangxinai.AttrAction.String("reject")))
+	assert.Equal(t, uint64(1), histogramCount(t, metrics, "xiangxinai.client.request.duration",
+		endpoint, xiangxinai.AttrModel.String(xiangxinai.DefaultModel), xiangxinai.AttrOutcome.String("success")))
+}
+
+func TestTelemetryRecordsError(t *testing.T) {
+	client, server, recorder := newTelemetryClient(t)
+	server.FailNext(http.StatusUnauthorized)
+
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	require.Error(t, err)
+
+	span := recorder.span(t)
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, "authentication", spanAttributes(span)[xiangxinai.AttrErrorType].AsString())
+	require.NotEmpty(t, span.Events())
+	assert.Equal(t, "exception", span.Events()[0].Name)
+
+	metrics := recorder.collect(t)
+	assert.Equal(t, int64(1), counterValue(t, metrics, "xiangxinai.client.errors", xiangxinai.AttrErrorType.String("authentication")))
+	assert.Equal(t, uint64(1), histogramCount(t, metrics, "xiangxinai.client.request.duration", xiangxinai.AttrOutcome.String("error")))
+}
+
+func TestTelemetryRecordsRetry(t *testing.T) {
+	client, server, recorder := newTelemetryClient(t, xiangxinai.WithRetryPolicy(&xiangxinai.DefaultRetryPolicy{
+		MaxRetries: 1,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+	}))
+	server.FailNext(http.StatusServiceUnavailable)
+
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+
+	span := recorder.span(t)
+	assert.Equal(t, int64(2), spanAttributes(span)[xiangxinai.AttrAttempts].AsInt64())
+	require.Len(t, span.Events(), 1)
+	assert.Equal(t, "retry", span.Events()[0].Name)
+
+	metrics := recorder.collect(t)
+	assert.Equal(t, int64(1), counterValue(t, metrics, "xiangxinai.client.retries", xiangxinai.AttrErrorType.String("server")))
+	assert.Equal(t, uint64(1), histogramCount(t, metrics, "xiangxinai.client.request.duration", xiangxinai.AttrOutcome.String("success")))
+}
+
+func TestTelemetryRecordsDegradedResponse(t *testing.T) {
+	client, server, recorder := newTelemetryClient(t, xiangxinai.WithFailureMode(xiangxinai.FailOpen))
+	server.Fail(xiangxintest.Fault{Status: http.StatusServiceUnavailable})
+
+	result, err := client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.True(t, result.Degraded)
+
+	span := recorder.span(t)
+	attrs := spanAttributes(span)
+	assert.True(t, attrs[xiangxinai.AttrDegraded].AsBool())
+	assert.Equal(t, "server", attrs[xiangxinai.AttrErrorType].AsString())
+
+	metrics := recorder.collect(t)
+	assert.Equal(t, int64(1), counterValue(t, metrics, "xiangxinai.client.errors", xiangxinai.AttrErrorType.String("server")))
+	assert.Equal(t, uint64(1), histogramCount(t, metrics, "xiangxinai.client.request.duration", xiangxinai.AttrOutcome.String("degraded")))
+}
@@ -2,40 +2,80 @@ package xiangxinai
 
 // Message Message model
 type Message struct {
-	Role    string      `json:"role"`    // Message role: user, assistant
-	Content interface{} `json:"content"` // Message content, can be string or []interface{} (multimodal)
+	Role    string         `json:"role"`    // Message role: user, system, assistant
+	Content MessageContent `json:"content"` // Message content, plain text or multimodal parts
 }
 
 // NewMessage Create new message
 func NewMessage(role, content string) *Message {
 	return &Message{
 		Role:    role,
-		Content: content,
+		Content: NewTextContent(content),
+	}
+}
+
+// NewMultimodalMessage Create new multimodal message from content parts
+//
+// Example:
+//
+//	msg := xiangxinai.NewMultimodalMessage("user",
+//		xiangxinai.NewTextPart("Is this image safe?"),
+//		xiangxinai.NewImageURLPart("https://example.com/image.jpg"),
+//	)
+func NewMultimodalMessage(role string, parts ...ContentPart) *Message {
+	return &Message{
+		Role:    role,
+		Content: NewPartsContent(parts...),
 	}
 }
 
 // GuardrailRequest Guardrail detection request model
 type GuardrailRequest struct {
-	Model    string     `json:"model"`    // Model name
-	Messages []*Message `json:"messages"` // Message list
+	Model     string                 `json:"model"`                // Model name
+	Messages  []*Message             `json:"messages"`             // Message list
+	ExtraBody map[string]interface{} `json:"extra_body,omitempty"` // Extra parameters, e.g. xxai_app_user_id
+}
+
+// InputRequest Request of the /guardrails/input endpoint, sent by CheckPrompt
+type InputRequest struct {
+	Input  string `json:"input"`                      // User input
+	UserID string `json:"xxai_app_user_id,omitempty"` // Tenant AI application user ID
+}
+
+// OutputRequest Request of the /guardrails/output endpoint, sent by CheckResponseCtx
+type OutputRequest struct {
+	Input  string `json:"input"`                      // User input
+	Output string `json:"output"`                     // Model output
+	UserID string `json:"xxai_app_user_id,omitempty"` // Tenant AI application user ID
 }
 
 // ComplianceResult Compliance detection result
 type ComplianceResult struct {
-	RiskLevel  string   `json:"risk_level"` // Risk level: no_risk, low_risk, medium_risk, high_risk
-	Categories []string `json:"categories"` // Risk category list
+	RiskLevel  RiskLevel `json:"risk_level"` // Risk level: no_risk, low_risk, medium_risk, high_risk
+	Categories []string  `json:"categories"` // Risk category list
 }
 
 // SecurityResult Security detection result
 type SecurityResult struct {
-	RiskLevel  string   `json:"risk_level"` // Risk level: no_risk, low_risk, medium_risk, high_risk
-	Categories []string `json:"categories"` // Risk category list
+	RiskLevel  RiskLevel `json:"risk_level"` // Risk level: no_risk, low_risk, medium_risk, high_risk
+	Categories []string  `json:"categories"` // Risk category list
 }
 
 // DataSecurityResult Data security detection result
 type DataSecurityResult struct {
-	RiskLevel  string   `json:"risk_level"` // Risk level: no_risk, low_risk, medium_risk, high_risk
-	Categories []string `json:"categories"` // Sensitive data category list
+	RiskLevel  RiskLevel    `json:"risk_level"`         // Risk level: no_risk, low_risk, medium_risk, high_risk
+	Categories []string     `json:"categories"`         // Sensitive data category list
+	Entities   []DataEntity `json:"entities,omitempty"` // Locations of the detected data, if returned by the server
+}
+
+// DataEntity Location of detected sensitive data in the checked text
+//
+// Start and End are character (Unicode code point) offsets, End exclusive.
+type DataEntity struct {
+	Category string `json:"category"`       // Sensitive data category
+	Start    int    `json:"start"`          // Offset of the first character
+	End      int    `json:"end"`            // Offset after the last character
+	Text     string `json:"text,omitempty"` // Matched text, if returned
 }
 
 // GuardrailResult Guardrail detection result
@@ -49,25 +89,35 @@ type GuardrailResult struct {
 type GuardrailResponse struct {
 	ID                string           `json:"id"`                  // Request unique identifier
 	Result            *GuardrailResult `json:"result"`              // Detection result
-	OverallRiskLevel  string           `json:"overall_risk_level"`  // Overall risk level: no_risk, low_risk, medium_risk, high_risk
-	SuggestAction     string           `json:"suggest_action"`      // Suggested action: pass, reject, replace
+	OverallRiskLevel  RiskLevel        `json:"overall_risk_level"`  // Overall risk level: no_risk, low_risk, medium_risk, high_risk
+	SuggestAction     Action           `json:"suggest_action"`      // Suggested action: pass, reject, replace
 	SuggestAnswer     *string          `json:"suggest_answer"`      // Suggested answer content
 	Score             *float64         `json:"score"`               // Detection confidence score
+
+	Cached         bool   `json:"cached,omitempty"`          // Served from the client response cache
+	Degraded       bool   `json:"degraded,omitempty"`        // Synthesized by the client failure mode, not returned by the API
+	DegradedReason string `json:"degraded_reason,omitempty"` // Error that caused the degraded response
+
+	Decision *Decision `json:"decision,omitempty"` // Locally decided action, set when a Policy is attached
+
+	Redactions *Redactions `json:"-"` // Values redacted before sending, set when WithRedaction redacted the request
+
+	batch *BatchResponse // Item results of a /guardrails/batch request, passed to BatchInterceptor; the other fields are empty
 }
 
 // IsSafe Check if the content is safe
 func (r *GuardrailResponse) IsSafe() bool {
-	return r.SuggestAction == "pass"
+	return r.SuggestAction == ActionPass
 }
 
 // IsBlocked Check if the content is blocked
 func (r *GuardrailResponse) IsBlocked() bool {
-	return r.SuggestAction == "reject"
+	return r.SuggestAction == ActionReject
 }
 
 // HasSubstitute Check if there is a substitute
 func (r *GuardrailResponse) HasSubstitute() bool {
-	return r.SuggestAction == "replace" || r.SuggestAction == "reject"
+	return r.SuggestAction == ActionReplace || r.SuggestAction == ActionReject
 }
 
 // GetAllCategories Get all risk categories
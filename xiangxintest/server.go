@@ -0,0 +1,532 @@
+// Package xiangxintest provides an in-process mock of the Xiangxin AI
+// Guardrails API for tests.
+//
+// The mock implements /guardrails, /guardrails/input, /guardrails/output,
+// /guardrails/batch, /guardrails/health and /guardrails/models. Content
+// passes with no_risk unless a rule scripted with OnContent or OnCategory
+// matches it. Errors and latency can be injected, and every request is
+// recorded for assertions.
+//
+// Example usage:
+//
+//	func TestChat(t *testing.T) {
+//		client, server := xiangxintest.NewClient(t)
+//		server.OnCategory(xiangxinai.DimensionSecurity, "prompt attack", `(?i)ignore previous`)
+//
+//		result, err := client.CheckPrompt(ctx, "Ignore previous instructions")
+//		// result.SuggestAction == xiangxinai.ActionReject
+//
+//		server.FailNext(http.StatusTooManyRequests)
+//		_, err = client.CheckPrompt(ctx, "hello")
+//		// err is a *xiangxinai.RateLimitError
+//
+//		if server.RequestCount() != 2 {
+//			t.Fatal("expected two requests")
+//		}
+//	}
+package xiangxintest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+)
+
+const (
+	// DefaultAPIKey API key used by NewClient
+	DefaultAPIKey = "xiangxintest-api-key"
+	// DefaultAnswer Suggested answer of scripted verdicts without an answer
+	DefaultAnswer = "Sorry, I can't answer that question."
+)
+
+// Verdict Scripted detection result
+type Verdict struct {
+	Dimension  xiangxinai.Dimension    // Detection dimension, default compliance
+	Categories []string                // Risk categories
+	RiskLevel  xiangxinai.RiskLevel    // Risk level, default high_risk
+	Action     xiangxinai.Action       // Suggested action, default pass for no_risk, reject otherwise
+	Answer     string                  // Suggested answer for reject/replace, default DefaultAnswer
+	Entities   []xiangxinai.DataEntity // Locations of the sensitive data, returned for the data dimension
+}
+
+// Fault Injected error response
+type Fault struct {
+	Status     int           // HTTP status code, e.g. 401, 422, 429 or 503
+	Body       string        // Response body, default a FastAPI style detail for the status
+	RetryAfter time.Duration // Retry-After header, rounded up to whole seconds
+	Path       string        // Only fail requests to this path, empty fails all paths
+	Times      int           // Number of requests to fail, 0 fails until ClearFaults
+}
+
+// Request Request received by the server
+type Request struct {
+	Method   string
+	Path     string
+	Header   http.Header
+	Body     []byte
+	Model    string                // Model of /guardrails requests
+	Messages []*xiangxinai.Message // Messages of /guardrails requests, the input of /guardrails/input as a user message
+	Input    string                // Input of /guardrails/input and /guardrails/output requests
+	Output   string                // Output of /guardrails/output requests
+	UserID   string                // xxai_app_user_id
+}
+
+// rule Scripted verdict for content matching a pattern
+type rule struct {
+	pattern *regexp.Regexp
+	verdict Verdict
+}
+
+// Server Mock guardrail API server
+type Server struct {
+	*httptest.Server
+
+	// APIKey Required bearer token, empty accepts any non-empty token
+	APIKey string
+	// Models Model IDs returned by /guardrails/models; IDs ending in -VL are listed with image input
+	Models []string
+	// DisableBatch Answer /guardrails/batch with 404, as servers without batch support do
+	DisableBatch bool
+
+	mu       sync.Mutex
+	rules    []rule
+	faults   []*Fault
+	latency  time.Duration
+	requests []Request
+	seq      int
+}
+
+// NewServer Start a new mock server, the caller must call Close
+func NewServer() *Server {
+	s := &Server{
+		Models: []string{xiangxinai.DefaultModel, xiangxinai.DefaultImageModel},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// NewClient Start a mock server and return a client pointed at it
+//
+// The server is closed when the test finishes. Retries are disabled so
+// injected errors surface immediately; pass WithMaxRetries or WithRetryPolicy
+// to test retries.
+func NewClient(tb testing.TB, opts ...xiangxinai.Option) (*xiangxinai.Client, *Server) {
+	tb.Helper()
+	s := NewServer()
+	tb.Cleanup(s.Close)
+
+	client, err := s.Client(opts...)
+	if err != nil {
+		tb.Fatalf("xiangxintest: failed to create client: %v", err)
+	}
+	return client, s
+}
+
+// Client Return a client pointed at the server
+func (s *Server) Client(opts ...xiangxinai.Option) (*xiangxinai.Client, error) {
+	apiKey := s.APIKey
+	if apiKey == "" {
+		apiKey = DefaultAPIKey
+	}
+	defaults := []xiangxinai.Option{
+		xiangxinai.WithBaseURL(s.URL),
+		xiangxinai.WithMaxRetries(0),
+	}
+	return xiangxinai.NewClient(apiKey, append(defaults, opts...)...)
+}
+
+// OnContent Return the verdict for content matching the regular expression
+//
+// Rules are evaluated in order against every checked text; the first match
+// wins. OnContent panics if pattern is not a valid regular expression.
+func (s *Server) OnContent(pattern string, verdict Verdict) *Server {
+	re := regexp.MustCompile(pattern)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{pattern: re, verdict: verdict})
+	return s
+}
+
+// OnCategory Flag content matching the regular expression with a high risk category
+func (s *Server) OnCategory(dimension xiangxinai.Dimension, category, pattern string) *Server {
+	return s.OnContent(pattern, Verdict{
+		Dimension:  dimension,
+		Categories: []string{category},
+		RiskLevel:  xiangxinai.HighRisk,
+	})
+}
+
+// Fail Inject an error response
+func (s *Server) Fail(fault Fault) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+	return s
+}
+
+// FailNext Fail the next request with the given status code
+func (s *Server) FailNext(status int) *Server {
+	return s.Fail(Fault{Status: status, Times: 1})
+}
+
+// ClearFaults Remove all injected errors
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// SetLatency Delay every response, honoring request cancellation
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests Return the recorded requests
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest Return the last recorded request
+func (s *Server) LastRequest() (Request, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return Request{}, false
+	}
+	return s.requests[len(s.requests)-1], true
+}
+
+// RequestCount Return the number of recorded requests, optionally only to the given paths
+func (s *Server) RequestCount(paths ...string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(paths) == 0 {
+		return len(s.requests)
+	}
+	count := 0
+	for _, req := range s.requests {
+		for _, path := range paths {
+			if req.Path == path {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// Reset Remove rules, faults, latency and recorded requests
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = nil
+	s.faults = nil
+	s.latency = 0
+	s.requests = nil
+}
+
+// handle Serve a request
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req, err := parseRequest(r, body)
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	latency := s.latency
+	fault := s.takeFaultLocked(r.URL.Path)
+	s.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+
+	if fault != nil {
+		writeFault(w, fault)
+		return
+	}
+
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if auth == "" || (s.APIKey != "" && auth != s.APIKey) {
+		writeFault(w, &Fault{Status: http.StatusUnauthorized})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/guardrails/health":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "healthy",
+			"version":    "xiangxintest",
+			"components": map[string]interface{}{"model": map[string]interface{}{"status": "healthy"}},
+		})
+		return
+	case "/guardrails/models":
+		writeJSON(w, http.StatusOK, s.models())
+		return
+	case "/guardrails", "/guardrails/input", "/guardrails/output":
+	case "/guardrails/batch":
+		if s.DisableBatch {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not Found"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"detail": "Not Found"})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]interface{}{"detail": "Method Not Allowed"})
+		return
+	}
+	if err != nil {
+		writeFault(w, &Fault{Status: http.StatusUnprocessableEntity, Body: validationBody("body", err.Error())})
+		return
+	}
+
+	if r.URL.Path == "/guardrails/batch" {
+		writeJSON(w, http.StatusOK, s.respondBatch(body))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.respond(req))
+}
+
+// respondBatch Build the batch detection response
+func (s *Server) respondBatch(body []byte) map[string]interface{} {
+	var batch struct {
+		Items []struct {
+			ID        string                 `json:"id"`
+			Model     string                 `json:"model"`
+			Messages  []*xiangxinai.Message  `json:"messages"`
+			ExtraBody map[string]interface{} `json:"extra_body"`
+		} `json:"items"`
+	}
+	json.Unmarshal(body, &batch)
+
+	results := make([]map[string]interface{}, 0, len(batch.Items))
+	for _, item := range batch.Items {
+		if len(item.Messages) == 0 {
+			results = append(results, map[string]interface{}{
+				"id":    item.ID,
+				"error": map[string]interface{}{"status_code": http.StatusUnprocessableEntity, "detail": "messages cannot be empty"},
+			})
+			continue
+		}
+		resp := s.respond(Request{Model: item.Model, Messages: item.Messages})
+		results = append(results, map[string]interface{}{"id": item.ID, "result": resp})
+	}
+	return map[string]interface{}{"results": results}
+}
+
+// takeFaultLocked Return the next fault for the path, must be called with mu held
+func (s *Server) takeFaultLocked(path string) *Fault {
+	for i, fault := range s.faults {
+		if fault.Path != "" && fault.Path != path {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+// respond Build the detection response for a request
+func (s *Server) respond(req Request) *xiangxinai.GuardrailResponse {
+	texts := []string{req.Input, req.Output}
+	for _, msg := range req.Messages {
+		texts = append(texts, msg.Content.String())
+	}
+
+	s.mu.Lock()
+	s.seq++
+	id := fmt.Sprintf("guardrails-xiangxintest-%d", s.seq)
+	var verdict *Verdict
+	for _, rule := range s.rules {
+		for _, text := range texts {
+			if text != "" && rule.pattern.MatchString(text) {
+				v := rule.verdict
+				verdict = &v
+				break
+			}
+		}
+		if verdict != nil {
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	resp := &xiangxinai.GuardrailResponse{
+		ID: id,
+		Result: &xiangxinai.GuardrailResult{
+			Compliance: &xiangxinai.ComplianceResult{RiskLevel: xiangxinai.NoRisk, Categories: []string{}},
+			Security:   &xiangxinai.SecurityResult{RiskLevel: xiangxinai.NoRisk, Categories: []string{}},
+			Data:       &xiangxinai.DataSecurityResult{RiskLevel: xiangxinai.NoRisk, Categories: []string{}},
+		},
+		OverallRiskLevel: xiangxinai.NoRisk,
+		SuggestAction:    xiangxinai.ActionPass,
+	}
+	if verdict == nil {
+		return resp
+	}
+
+	level := verdict.RiskLevel
+	if level == "" {
+		level = xiangxinai.HighRisk
+	}
+	categories := verdict.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	switch verdict.Dimension {
+	case xiangxinai.DimensionSecurity:
+		resp.Result.Security = &xiangxinai.SecurityResult{RiskLevel: level, Categories: categories}
+	case xiangxinai.DimensionData:
+		resp.Result.Data = &xiangxinai.DataSecurityResult{RiskLevel: level, Categories: categories, Entities: verdict.Entities}
+	default:
+		resp.Result.Compliance = &xiangxinai.ComplianceResult{RiskLevel: level, Categories: categories}
+	}
+	resp.OverallRiskLevel = level
+
+	action := verdict.Action
+	if action == "" {
+		action = xiangxinai.ActionReject
+		if level == xiangxinai.NoRisk {
+			action = xiangxinai.ActionPass
+		}
+	}
+	resp.SuggestAction = action
+	if action == xiangxinai.ActionReject || action == xiangxinai.ActionReplace {
+		answer := verdict.Answer
+		if answer == "" {
+			answer = DefaultAnswer
+		}
+		resp.SuggestAnswer = &answer
+	}
+	return resp
+}
+
+// models Build the /guardrails/models response
+func (s *Server) models() map[string]interface{} {
+	data := make([]map[string]interface{}, 0, len(s.Models))
+	for _, model := range s.Models {
+		modalities := []xiangxinai.Modality{xiangxinai.ModalityText}
+		if (&xiangxinai.ModelInfo{ID: model}).SupportsImages() {
+			modalities = append(modalities, xiangxinai.ModalityImage)
+		}
+		data = append(data, map[string]interface{}{
+			"id":         model,
+			"object":     "model",
+			"owned_by":   "xiangxinai",
+			"modalities": modalities,
+			"dimensions": xiangxinai.Dimensions,
+		})
+	}
+	return map[string]interface{}{"object": "list", "data": data}
+}
+
+// parseRequest Parse the recorded fields of a request
+func parseRequest(r *http.Request, body []byte) (Request, error) {
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+	if r.Method != http.MethodPost {
+		return req, nil
+	}
+
+	var data struct {
+		Model     string                 `json:"model"`
+		Messages  []*xiangxinai.Message  `json:"messages"`
+		Input     string                 `json:"input"`
+		Output    string                 `json:"output"`
+		UserID    string                 `json:"xxai_app_user_id"`
+		ExtraBody map[string]interface{} `json:"extra_body"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return req, err
+	}
+
+	req.Model = data.Model
+	req.Messages = data.Messages
+	req.Input = data.Input
+	req.Output = data.Output
+	req.UserID = data.UserID
+	if userID, ok := data.ExtraBody["xxai_app_user_id"].(string); ok {
+		req.UserID = userID
+	}
+	if r.URL.Path == "/guardrails/input" && data.Input != "" {
+		req.Messages = []*xiangxinai.Message{xiangxinai.NewMessage("user", data.Input)}
+	}
+	return req, nil
+}
+
+// writeFault Write an injected error response
+func writeFault(w http.ResponseWriter, fault *Fault) {
+	if fault.RetryAfter > 0 {
+		seconds := int((fault.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	body := fault.Body
+	if body == "" {
+		body = defaultFaultBody(fault.Status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(fault.Status)
+	io.WriteString(w, body)
+}
+
+// defaultFaultBody Return a FastAPI style error body for the status
+func defaultFaultBody(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return `{"detail":"Invalid API key"}`
+	case http.StatusUnprocessableEntity:
+		return validationBody("messages", "field required")
+	case http.StatusTooManyRequests:
+		return `{"detail":"Rate limit exceeded"}`
+	default:
+		data, _ := json.Marshal(map[string]string{"detail": http.StatusText(status)})
+		return string(data)
+	}
+}
+
+// validationBody Return a FastAPI style 422 body
+func validationBody(field, msg string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"detail": []map[string]interface{}{
+			{"loc": []string{"body", field}, "msg": msg, "type": "value_error"},
+		},
+	})
+	return string(data)
+}
+
+// writeJSON Write a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,213 @@
+package xiangxintest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	xiangxinai "github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go"
+	"github.com/xiangxinai/xiangxin-guardrails/client/xiangxinai-go/xiangxintest"
+)
+
+func TestPassesByDefault(t *testing.T) {
+	client, _ := xiangxintest.NewClient(t)
+	result, err := client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.Equal(t, xiangxinai.NoRisk, result.OverallRiskLevel)
+	assert.Equal(t, xiangxinai.ActionPass, result.SuggestAction)
+	assert.Nil(t, result.SuggestAnswer)
+	assert.NotEmpty(t, result.ID)
+}
+
+func TestOnContent(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.OnContent(`(?i)password`, xiangxintest.Verdict{
+		Dimension:  xiangxinai.DimensionData,
+		Categories: []string{"API_KEY"},
+		RiskLevel:  xiangxinai.MediumRisk,
+		Action:     xiangxinai.ActionReplace,
+		Answer:     "Please remove the secret.",
+		Entities:   []xiangxinai.DataEntity{{Category: "API_KEY", Start: 13, End: 19, Text: "hunter"}},
+	})
+
+	result, err := client.CheckPrompt(context.Background(), "My Password: hunter")
+	require.NoError(t, err)
+	assert.Equal(t, xiangxinai.MediumRisk, result.OverallRiskLevel)
+	assert.Equal(t, xiangxinai.ActionReplace, result.SuggestAction)
+	assert.Equal(t, "Please remove the secret.", *result.SuggestAnswer)
+	require.NotNil(t, result.Result.Data)
+	assert.Equal(t, []string{"API_KEY"}, result.Result.Data.Categories)
+	assert.Equal(t, "hunter", result.Result.Data.Entities[0].Text)
+	assert.Equal(t, xiangxinai.NoRisk, result.Result.Compliance.RiskLevel)
+}
+
+func TestOnCategoryFirstRuleWins(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.
+		OnCategory(xiangxinai.DimensionSecurity, "prompt attack", `ignore`).
+		OnCategory(xiangxinai.DimensionCompliance, "violence", `ignore|attack`)
+
+	result, err := client.CheckPrompt(context.Background(), "ignore the rules")
+	require.NoError(t, err)
+	assert.Equal(t, xiangxinai.HighRisk, result.Result.Security.RiskLevel)
+	assert.Equal(t, []string{"prompt attack"}, result.Result.Security.Categories)
+	assert.Equal(t, xiangxinai.NoRisk, result.Result.Compliance.RiskLevel)
+	assert.Equal(t, xiangxinai.ActionReject, result.SuggestAction)
+	assert.Equal(t, xiangxintest.DefaultAnswer, *result.SuggestAnswer)
+
+	// Rules match the output of response checks too
+	result, err = client.CheckResponseCtx(context.Background(), "hello", "an attack plan")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"violence"}, result.Result.Compliance.Categories)
+}
+
+func TestFaults(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+
+	server.Fail(xiangxintest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond, Times: 1})
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	var rateLimitErr *xiangxinai.RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr), "got %v", err)
+	var apiErr *xiangxinai.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, 2*time.Second, apiErr.RetryAfter)
+	assert.Equal(t, "Rate limit exceeded", apiErr.Detail)
+
+	// The fault was used up
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+
+	// Faults limited to a path
+	server.Fail(xiangxintest.Fault{Status: http.StatusServiceUnavailable, Path: "/guardrails/output"})
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+	_, err = client.CheckResponseCtx(context.Background(), "hello", "world")
+	var serverErr *xiangxinai.ServerError
+	assert.True(t, errors.As(err, &serverErr), "got %v", err)
+	_, err = client.CheckResponseCtx(context.Background(), "hello", "world")
+	assert.Error(t, err)
+
+	server.ClearFaults()
+	_, err = client.CheckResponseCtx(context.Background(), "hello", "world")
+	assert.NoError(t, err)
+
+	// Custom bodies are returned as-is
+	server.Fail(xiangxintest.Fault{Status: http.StatusBadRequest, Body: `{"detail":"bad request"}`, Times: 1})
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "bad request", apiErr.Detail)
+}
+
+func TestFailNextValidation(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.FailNext(http.StatusUnprocessableEntity)
+
+	_, err := client.CheckPrompt(context.Background(), "hello")
+	var validationErr *xiangxinai.ValidationError
+	require.True(t, errors.As(err, &validationErr), "got %v", err)
+	var apiErr *xiangxinai.APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Len(t, apiErr.FieldErrors, 1)
+	assert.Equal(t, "body.messages", apiErr.FieldErrors[0].Field())
+}
+
+func TestAPIKey(t *testing.T) {
+	server := xiangxintest.NewServer()
+	defer server.Close()
+	server.APIKey = "secret-key"
+
+	client, err := xiangxinai.NewClient("wrong-key", xiangxinai.WithBaseURL(server.URL), xiangxinai.WithMaxRetries(0))
+	require.NoError(t, err)
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	var authErr *xiangxinai.AuthenticationError
+	assert.True(t, errors.As(err, &authErr), "got %v", err)
+
+	client, err = server.Client()
+	require.NoError(t, err)
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	assert.NoError(t, err)
+}
+
+func TestLatency(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.CheckPrompt(ctx, "hello")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	server.SetLatency(50 * time.Millisecond)
+	start = time.Now()
+	_, err = client.CheckPrompt(context.Background(), "hello")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestRecordsRequests(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	ctx := context.Background()
+
+	_, err := client.CheckConversationWithModel(ctx, []*xiangxinai.Message{
+		xiangxinai.NewMessage("user", "question"),
+		xiangxinai.NewMessage("assistant", "answer"),
+	}, "Custom-Model", "user-1")
+	require.NoError(t, err)
+	_, err = client.CheckPrompt(ctx, "prompt")
+	require.NoError(t, err)
+	_, err = client.CheckResponseCtx(ctx, "prompt", "response")
+	require.NoError(t, err)
+
+	requests := server.Requests()
+	require.Len(t, requests, 3)
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, "/guardrails", requests[0].Path)
+	assert.Equal(t, "Custom-Model", requests[0].Model)
+	assert.Equal(t, "user-1", requests[0].UserID)
+	require.Len(t, requests[0].Messages, 2)
+	assert.Equal(t, "answer", requests[0].Messages[1].Content.String())
+	assert.Equal(t, "Bearer "+xiangxintest.DefaultAPIKey, requests[0].Header.Get("Authorization"))
+
+	assert.Equal(t, "/guardrails/input", requests[1].Path)
+	assert.Equal(t, "prompt", requests[1].Input)
+	require.Len(t, requests[1].Messages, 1)
+	assert.Equal(t, "prompt", requests[1].Messages[0].Content.String())
+
+	last, ok := server.LastRequest()
+	require.True(t, ok)
+	assert.Equal(t, "/guardrails/output", last.Path)
+	assert.Equal(t, "response", last.Output)
+	assert.JSONEq(t, `{"input":"prompt","output":"response"}`, string(last.Body))
+
+	assert.Equal(t, 3, server.RequestCount())
+	assert.Equal(t, 2, server.RequestCount("/guardrails", "/guardrails/output"))
+
+	server.Reset()
+	assert.Zero(t, server.RequestCount())
+	_, ok = server.LastRequest()
+	assert.False(t, ok)
+}
+
+func TestModelsAndHealth(t *testing.T) {
+	client, server := xiangxintest.NewClient(t)
+	server.Models = []string{"Guard-Text", "Guard-VL"}
+
+	models, err := client.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	assert.False(t, models.SupportsImages("Guard-Text"))
+	assert.True(t, models.SupportsImages("Guard-VL"))
+
+	status, err := client.Health(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.IsHealthy())
+	assert.Empty(t, status.Unhealthy())
+}